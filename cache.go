@@ -0,0 +1,98 @@
+package stom
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// planKey identifies results of analysis of a type
+type planKey struct {
+	typ  reflect.Type
+	scan scanConfig
+}
+
+// Cache of analyzed types shared by ConvertToMap and SToM converters
+var (
+	plansMutex sync.RWMutex
+	plans      = map[planKey]tags{}
+	generation uint64 // bumped by ResetCache, so plans computed before it are not stored
+)
+
+// Identifiers of values that are parts of plan keys, see internKey
+var (
+	keysMutex sync.Mutex
+	keys      = map[interface{}]string{}
+)
+
+// internKey returns a short identifier of a comparable value, like a type or a tag parser,
+// so lists of such values can be encoded into comparable strings. Identifiers are never
+// dropped, equal values share an identifier
+func internKey(v interface{}) string {
+	keysMutex.Lock()
+	defer keysMutex.Unlock()
+
+	key, ok := keys[v]
+	if !ok {
+		key = strconv.Itoa(len(keys))
+		keys[v] = key
+	}
+
+	return key
+}
+
+// cachedTagValues returns results of extractTagValues, analyzing each type
+// only once for the same settings
+func cachedTagValues(typ reflect.Type, config Config) tags {
+	if !config.scanConfig.comparable() {
+		return extractTagValues(typ, config)
+	}
+
+	key := planKey{typ: typ, scan: config.scanConfig.effective()}
+
+	plansMutex.RLock()
+	tagmap, ok := plans[key]
+	gen := generation
+	plansMutex.RUnlock()
+
+	if ok {
+		return tagmap
+	}
+
+	tagmap = extractTagValues(typ, config)
+
+	plansMutex.Lock()
+	if gen == generation {
+		plans[key] = tagmap
+	}
+	plansMutex.Unlock()
+
+	return tagmap
+}
+
+// WarmCache analyzes types of given structures with given settings in advance,
+// so the first conversions do not pay for it. It's handy to call at startup.
+// Returns an error if some of given values is not a structure or
+// has invalid tag options or duplicate keys in strict mode
+func WarmCache(config Config, samples ...interface{}) error {
+	for _, s := range samples {
+		typ, err := getStructType(s)
+		if err != nil {
+			return err
+		}
+
+		if err := checkTags(typ, cachedTagValues(typ, config), config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetCache drops all analyzed types
+func ResetCache() {
+	plansMutex.Lock()
+	plans = map[planKey]tags{}
+	generation++
+	plansMutex.Unlock()
+}
@@ -0,0 +1,390 @@
+package stom
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// FieldName is a pseudo tag that can be used in a chain of tags to fall back
+// to the name of a field, e.g. WithTags("db", "json", FieldName)
+const FieldName = ""
+
+// Config is an immutable set of conversion settings. Its With... methods return
+// modified copies, so a Config can be built once and shared between goroutines.
+// Use NewConfig to get a Config with default settings
+type Config struct {
+	scanConfig
+
+	policy       Policy
+	defaultValue interface{}
+	strict       bool
+	forceReflect bool
+	unwrapValuer bool
+	// indexBrackets formats keys of flattened elements like "items[0].name"
+	indexBrackets bool
+	converters    *valueConverters
+	// parserChain is a chain of tag parsers that replaces tags if not nil
+	parserChain tagParsers
+}
+
+// scanConfig holds settings that affect analysis of types,
+// results of the analysis are cached per type and scanConfig
+type scanConfig struct {
+	// tags is a space separated chain of tags, space cannot appear in a tag name
+	tags string
+	// parsers identifies the chain of tag parsers by values of parsers
+	parsers string
+	// opaque tells that some of tag parsers are not comparable, so analysis is not cached
+	opaque     bool
+	structMode StructMode
+	sliceMode  SliceMode
+	separator  string
+	naming     NamingStrategy
+	jsonCompat bool
+	// hooks are hooks in order of precedence, one byte per hook
+	hooks string
+	// converted is a set of types converted by value converters of the Config,
+	// structures of such types are never nested or flattened
+	converted string
+}
+
+// effective returns settings that are really used for analysis. In encoding/json
+// compatibility mode tags, naming strategy, struct and slice modes are ignored
+func (c scanConfig) effective() scanConfig {
+	if !c.jsonCompat {
+		return c
+	}
+
+	return scanConfig{
+		tags:       "json " + FieldName,
+		structMode: StructAsValue,
+		jsonCompat: true,
+	}
+}
+
+// comparable checks whether scanConfig can be compared and used as a cache key,
+// custom implementations of NamingStrategy and TagParser may be not comparable
+func (c scanConfig) comparable() bool {
+	return !c.opaque && (c.naming == nil || reflect.TypeOf(c.naming).Comparable())
+}
+
+// equal checks whether two scanConfigs are the same
+func (c scanConfig) equal(other scanConfig) bool {
+	return c.comparable() && other.comparable() && c == other
+}
+
+// NewConfig creates Config with default settings: tag "db", PolicyUseDefault
+// with nil as default value, struct and slice fields kept as values, "." as separator and DefaultHooks
+func NewConfig() Config {
+	return Config{
+		scanConfig: scanConfig{
+			tags:       "db",
+			structMode: StructAsValue,
+			sliceMode:  SliceAsValue,
+			separator:  ".",
+			hooks:      encodeHooks(DefaultHooks()),
+		},
+		policy: PolicyUseDefault,
+	}
+}
+
+// WithTag returns a copy of Config that scans for given tag in structures
+func (c Config) WithTag(tag string) Config {
+	return c.WithTags(tag)
+}
+
+// WithTags returns a copy of Config that scans for given chain of tags in structures.
+// The first tag present on a field decides its key, "-" in this tag excludes the field.
+// Use FieldName as the last element to fall back to the name of a field
+func (c Config) WithTags(tags ...string) Config {
+	c.tags = strings.Join(tags, " ")
+	c.parserChain, c.parsers, c.opaque = nil, "", false
+	return c
+}
+
+// WithTagParsers returns a copy of Config that uses given chain of parsers instead of tags.
+// The first parser that recognizes a field decides its key.
+// Use FieldNameTag as the last element to fall back to the name of a field
+func (c Config) WithTagParsers(parsers ...TagParser) Config {
+	c.parserChain = append(tagParsers{}, parsers...)
+	c.parsers, c.opaque = c.parserChain.key()
+	c.tags = ""
+	return c
+}
+
+// WithPolicy returns a copy of Config with given policy for 'nil' values
+func (c Config) WithPolicy(policy Policy) Config {
+	c.policy = policy
+	return c
+}
+
+// WithDefault returns a copy of Config that puts given default value instead of 'nil' values
+func (c Config) WithDefault(defaultValue interface{}) Config {
+	c.defaultValue = defaultValue
+	return c
+}
+
+// WithStructMode returns a copy of Config with given mode for named fields of struct types
+func (c Config) WithStructMode(mode StructMode) Config {
+	c.structMode = mode
+	return c
+}
+
+// WithSliceMode returns a copy of Config with given mode for named fields of slice and array
+// types with struct elements. Elements recognized by hooks are never nested or flattened
+func (c Config) WithSliceMode(mode SliceMode) Config {
+	c.sliceMode = mode
+	return c
+}
+
+// WithIndexBrackets returns a copy of Config that formats keys of flattened elements
+// like "items[0].name" instead of "items.0.name"
+func (c Config) WithIndexBrackets(brackets bool) Config {
+	c.indexBrackets = brackets
+	return c
+}
+
+// WithSeparator returns a copy of Config with given separator for keys of flattened struct fields
+func (c Config) WithSeparator(separator string) Config {
+	c.separator = separator
+	return c
+}
+
+// WithStrict returns a copy of Config that fails conversions of structures
+// having several fields producing the same key
+func (c Config) WithStrict(strict bool) Config {
+	c.strict = strict
+	return c
+}
+
+// WithForceReflect returns a copy of Config that ignores ToMap() method of
+// structures implementing ToMappable and converts them using reflection
+func (c Config) WithForceReflect(force bool) Config {
+	c.forceReflect = force
+	return c
+}
+
+// WithNaming returns a copy of Config that names untagged exported fields
+// with given strategy. Fields are not named by default, so untagged fields are ignored
+func (c Config) WithNaming(naming NamingStrategy) Config {
+	c.naming = naming
+	return c
+}
+
+// WithJSONCompat returns a copy of Config that interprets structures exactly
+// as encoding/json does: keys are taken from "json" tags, untagged exported fields
+// use their names, values become the same as json.Unmarshal into interface{} gives.
+// Tags, naming strategy, struct and slice modes, policy, default value and value converters
+// are ignored in this mode
+func (c Config) WithJSONCompat(compat bool) Config {
+	c.jsonCompat = compat
+	return c
+}
+
+// WithHooks returns a copy of Config that recognizes values of fields with given hooks.
+// A value is converted by the first hook in the list it's recognized by, hooks that
+// are not listed are disabled. Structures recognized by hooks are never nested or flattened
+func (c Config) WithHooks(hooks ...Hook) Config {
+	c.hooks = encodeHooks(hooks)
+	return c
+}
+
+// WithUnwrapValuer returns a copy of Config that puts results of Value() of valid
+// driver.Valuer values, like sql.NullString, into resulting map instead of the values
+// themselves, e.g. plain string instead of sql.NullString. Errors of Value() fail
+// conversion in this mode, otherwise such values are treated as 'nil'
+func (c Config) WithUnwrapValuer(unwrap bool) Config {
+	c.unwrapValuer = unwrap
+	return c
+}
+
+// WithValueConverter returns a copy of Config that converts values of given concrete type
+// or of types implementing given interface with given converter. Such converters go
+// before ones registered with RegisterValueConverter. Structures converted by converters are never
+// nested or flattened. nil converter removes converter of the type
+func (c Config) WithValueConverter(typ reflect.Type, converter ValueConverter) Config {
+	c.converters = c.converters.with(typ, converter)
+	c.converted = c.converters.key()
+	return c
+}
+
+// Tag returns tag to look for in structures, the first one if there is a chain of tags.
+// Returns empty string if tag parsers are used
+func (c Config) Tag() string {
+	if tags := c.Tags(); len(tags) > 0 {
+		return tags[0]
+	}
+
+	return ""
+}
+
+// Tags returns chain of tags to look for in structures, nil if tag parsers are used
+func (c Config) Tags() []string {
+	if c.parserChain != nil {
+		return nil
+	}
+
+	return strings.Split(c.tags, " ")
+}
+
+// TagParsers returns chain of parsers that recognize fields of structures.
+// If the chain is not set, it's made of StructTag parsers for the chain of tags
+func (c Config) TagParsers() []TagParser {
+	if c.parserChain != nil {
+		return append([]TagParser{}, c.parserChain...)
+	}
+
+	return structTagParsers(c.Tags())
+}
+
+// Policy returns policy for 'nil' values
+func (c Config) Policy() Policy { return c.policy }
+
+// Default returns value that is put instead of 'nil' values
+func (c Config) Default() interface{} { return c.defaultValue }
+
+// StructMode returns mode for named fields of struct types
+func (c Config) StructMode() StructMode { return c.structMode }
+
+// SliceMode returns mode for named fields of slice and array types with struct elements
+func (c Config) SliceMode() SliceMode { return c.sliceMode }
+
+// IndexBrackets tells whether indices of flattened elements are put in brackets
+func (c Config) IndexBrackets() bool { return c.indexBrackets }
+
+// Separator returns separator for keys of flattened struct fields
+func (c Config) Separator() string { return c.separator }
+
+// Naming returns naming strategy for untagged fields
+func (c Config) Naming() NamingStrategy { return c.naming }
+
+// Strict tells whether duplicate keys are treated as an error
+func (c Config) Strict() bool { return c.strict }
+
+// ForceReflect tells whether ToMap() method of ToMappable structures is ignored
+func (c Config) ForceReflect() bool { return c.forceReflect }
+
+// Hooks returns hooks in order of precedence
+func (c Config) Hooks() []Hook { return decodeHooks(c.hooks) }
+
+// UnwrapValuer tells whether results of Value() of driver.Valuer values are put into resulting map
+func (c Config) UnwrapValuer() bool { return c.unwrapValuer }
+
+// JSONCompat tells whether encoding/json compatibility mode is on
+func (c Config) JSONCompat() bool { return c.jsonCompat }
+
+// useToMappable tells whether ToMap() method of ToMappable structures is called
+func (c Config) useToMappable() bool { return !c.forceReflect && !c.jsonCompat }
+
+// Package settings
+// They are used as defaults for initialization of new SToMs and by ConvertToMap.
+// Kept for backward compatibility, guarded for concurrent use
+var (
+	settingsMutex sync.RWMutex
+	settings      = NewConfig()
+)
+
+// PackageConfig returns a snapshot of package settings
+func PackageConfig() Config {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+
+	return settings
+}
+
+func updateSettings(update func(c Config) Config) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	settings = update(settings)
+}
+
+// SetTag sets package setting for tag to look for in incoming structures
+func SetTag(t string) {
+	updateSettings(func(c Config) Config { return c.WithTag(t) })
+}
+
+// SetTags sets package setting for chain of tags to look for in incoming structures
+func SetTags(tags ...string) {
+	updateSettings(func(c Config) Config { return c.WithTags(tags...) })
+}
+
+// SetTagParsers sets package setting for chain of tag parsers
+func SetTagParsers(parsers ...TagParser) {
+	updateSettings(func(c Config) Config { return c.WithTagParsers(parsers...) })
+}
+
+// SetDefault sets package default value to set instead of 'nil' in resulting maps
+func SetDefault(dv interface{}) {
+	updateSettings(func(c Config) Config { return c.WithDefault(dv) })
+}
+
+// SetPolicy sets package setting for policy. Policy defines what to do with
+// 'nil' values in resulting maps.
+// There are 5 policies:
+// - PolicyUseDefault - with this policy default value will be used instead of 'nil'
+// - PolicyExclude    - 'nil' values will be discarded
+// - PolicyOmitZero   - 'nil' and zero values will be discarded
+// - PolicyError      - conversion fails if 'nil' value is met
+// - PolicyKeepNil    - 'nil' values are kept as is regardless of default value
+func SetPolicy(p Policy) {
+	updateSettings(func(c Config) Config { return c.WithPolicy(p) })
+}
+
+// SetStructMode sets package setting for named fields of struct types.
+// Can be overridden for particular field with tag options "nest", "flatten" and "value"
+func SetStructMode(m StructMode) {
+	updateSettings(func(c Config) Config { return c.WithStructMode(m) })
+}
+
+// SetSliceMode sets package setting for named fields of slice and array types with struct elements.
+// Can be overridden for particular field with tag options "nest", "flatten" and "value"
+func SetSliceMode(m SliceMode) {
+	updateSettings(func(c Config) Config { return c.WithSliceMode(m) })
+}
+
+// SetIndexBrackets sets package setting that puts indices of flattened elements in brackets
+func SetIndexBrackets(brackets bool) {
+	updateSettings(func(c Config) Config { return c.WithIndexBrackets(brackets) })
+}
+
+// SetSeparator sets package setting for separator of flattened keys
+func SetSeparator(sep string) {
+	updateSettings(func(c Config) Config { return c.WithSeparator(sep) })
+}
+
+// SetNaming sets package setting for naming strategy of untagged fields
+func SetNaming(naming NamingStrategy) {
+	updateSettings(func(c Config) Config { return c.WithNaming(naming) })
+}
+
+// SetStrict sets package setting for strict mode. In strict mode structures
+// that have several fields producing the same key are not converted,
+// *DuplicateKeyError is returned instead.
+// Otherwise the shallowest field wins and fields on the same depth are ignored
+func SetStrict(strict bool) {
+	updateSettings(func(c Config) Config { return c.WithStrict(strict) })
+}
+
+// SetForceReflect sets package setting that makes ConvertToMap to ignore
+// ToMap() method of structures implementing ToMappable
+func SetForceReflect(force bool) {
+	updateSettings(func(c Config) Config { return c.WithForceReflect(force) })
+}
+
+// SetJSONCompat sets package setting for encoding/json compatibility mode
+func SetJSONCompat(compat bool) {
+	updateSettings(func(c Config) Config { return c.WithJSONCompat(compat) })
+}
+
+// SetUnwrapValuer sets package setting that makes ConvertToMap to put results
+// of Value() of driver.Valuer values into resulting maps
+func SetUnwrapValuer(unwrap bool) {
+	updateSettings(func(c Config) Config { return c.WithUnwrapValuer(unwrap) })
+}
+
+// SetHooks sets package setting for hooks that recognize values of fields
+func SetHooks(hooks ...Hook) {
+	updateSettings(func(c Config) Config { return c.WithHooks(hooks...) })
+}
@@ -0,0 +1,66 @@
+package stom
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Converter is a type-safe SToM converter for structures of type T.
+// Like SToM, it analyzes the type once and caches the results
+type Converter[T any] struct {
+	stom *Stom
+}
+
+// For creates a Converter for type T with package settings.
+// Returns an error if T is not a struct, if it has invalid tag options
+// or duplicate keys in strict mode
+func For[T any]() (*Converter[T], error) {
+	return ForConfig[T](PackageConfig())
+}
+
+// ForConfig creates a Converter for type T with given settings.
+// Returns an error if T is not a struct, if it has invalid tag options
+// or duplicate keys in strict mode
+func ForConfig[T any](config Config) (*Converter[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("provided type is not a struct but %v", typ.Kind())
+	}
+
+	s, err := newStom(typ, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Converter[T]{stom: s}, nil
+}
+
+// ToMap converts a structure to map[string]interface{}
+func (c *Converter[T]) ToMap(v T) (map[string]interface{}, error) {
+	return c.stom.ToMap(v)
+}
+
+// ToMapPtr converts a structure referenced by given pointer to map[string]interface{}.
+// Returns an error if the pointer is nil
+func (c *Converter[T]) ToMapPtr(v *T) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, fmt.Errorf("provided value is a nil pointer to %s", c.stom.typ)
+	}
+
+	return c.stom.ToMap(v)
+}
+
+// FromMap populates structure pointed by dst with values from given map
+func (c *Converter[T]) FromMap(m map[string]interface{}, dst *T) error {
+	return c.stom.FromMap(m, dst)
+}
+
+// TagValues returns list of cached tag values that were processed by Converter
+func (c *Converter[T]) TagValues() []string {
+	return c.stom.TagValues()
+}
+
+// Config returns settings of Converter
+func (c *Converter[T]) Config() Config {
+	return c.stom.config
+}
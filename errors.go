@@ -0,0 +1,99 @@
+package stom
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// KeyConflict describes a key produced by several fields of a structure
+type KeyConflict struct {
+	Key   string
+	Paths []string
+}
+
+// DuplicateKeyError is returned in strict mode if several fields of a structure
+// produce the same key
+type DuplicateKeyError struct {
+	Type      reflect.Type
+	Conflicts []KeyConflict
+}
+
+func (e *DuplicateKeyError) Error() string {
+	conflicts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		conflicts[i] = fmt.Sprintf("%s (%s)", c.Key, strings.Join(c.Paths, ", "))
+	}
+
+	return fmt.Sprintf("duplicate keys in type %s: %s", e.Type, strings.Join(conflicts, "; "))
+}
+
+// FieldError is returned when conversion of a particular field fails,
+// e.g. ToMap() method of a ToMappable field returns an error.
+// The original error is available via errors.Is and errors.As
+type FieldError struct {
+	// Path is a Go path to the field, e.g. "BasicItem.ParentItem.Base"
+	Path string
+	// Key is a key of the field in resulting map
+	Key string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s (key %s): %s", e.Path, e.Key, e.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// newElementError wraps an error that occurred while processing an element of a slice field,
+// so the path and the key of the field get index of the element
+func newElementError(index int, err error) *FieldError {
+	element := strconv.Itoa(index)
+	if fieldErr, ok := err.(*FieldError); ok {
+		return &FieldError{
+			Path: element + "." + fieldErr.Path,
+			Key:  element + "." + fieldErr.Key,
+			Err:  fieldErr.Err,
+		}
+	}
+
+	return &FieldError{Path: element, Key: element, Err: err}
+}
+
+// newFieldError wraps an error that occurred while processing given field.
+// Errors of fields of nested structures are merged so the path is full
+func newFieldError(f field, err error) *FieldError {
+	if fieldErr, ok := err.(*FieldError); ok {
+		return &FieldError{
+			Path: f.path + "." + fieldErr.Path,
+			Key:  f.name + "." + fieldErr.Key,
+			Err:  fieldErr.Err,
+		}
+	}
+
+	return &FieldError{Path: f.path, Key: f.name, Err: err}
+}
+
+// MappingError is returned if mappings refer to unknown types or fields.
+// It lists all problems found at once
+type MappingError struct {
+	Errors []error
+}
+
+func (e *MappingError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d mapping errors: %s", len(e.Errors), strings.Join(messages, "; "))
+}
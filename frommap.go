@@ -0,0 +1,388 @@
+package stom
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// FromMappable defines an entity that knows how to fill itself from map[string]interface{}.
+// It is a counterpart of ToMappable: if an entity implements this interface,
+// SToM will just call FromMap() method instead of scanning the tags.
+type FromMappable interface {
+	FromMap(m map[string]interface{}) error
+}
+
+// FromMapper defines a service that is able to fill something from map[string]interface{}
+type FromMapper interface {
+	FromMap(m map[string]interface{}, dst interface{}) error
+}
+
+// FromMapperFunc defines a function that implements FromMapper
+type FromMapperFunc func(m map[string]interface{}, dst interface{}) error
+
+// FromMap implements FromMapper
+func (f FromMapperFunc) FromMap(m map[string]interface{}, dst interface{}) error {
+	return f(m, dst)
+}
+
+var (
+	scannerType      = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType       = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	zeroableType     = reflect.TypeOf((*Zeroable)(nil)).Elem()
+	fromMappableType = reflect.TypeOf((*FromMappable)(nil)).Elem()
+	toMappableType   = reflect.TypeOf((*ToMappable)(nil)).Elem()
+
+	toMappableWithType = reflect.TypeOf((*ToMappableWith)(nil)).Elem()
+)
+
+// FromMap populates structure pointed by dst with values from given map.
+// SToM populates only structures it was initialized for
+func (s *Stom) FromMap(m map[string]interface{}, dst interface{}) error {
+	val, err := getStructPtrValue(dst)
+	if err != nil {
+		return err
+	}
+
+	if val.Type() != s.typ {
+		return fmt.Errorf("stom is set up to work with type %s, but %s given", s.typ, val.Type())
+	}
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if fromMappable, ok := dst.(FromMappable); ok {
+		return fromMappable.FromMap(m)
+	}
+
+	return fromMap(m, val, s.cache, s.config)
+}
+
+// ConvertFromMap populates structure pointed by dst with values from given map.
+// It is a counterpart of ConvertToMap: the same tag, policy and default value
+// from package settings are used to match keys of the map to fields of the structure
+func ConvertFromMap(m map[string]interface{}, dst interface{}) error {
+	return ConvertFromMapWith(m, dst, PackageConfig())
+}
+
+// ConvertFromMapWith populates structure pointed by dst with values from given map
+// using given settings
+func ConvertFromMapWith(m map[string]interface{}, dst interface{}, config Config) error {
+	if fromMappable, ok := dst.(FromMappable); ok {
+		return fromMappable.FromMap(m)
+	}
+
+	val, err := getStructPtrValue(dst)
+	if err != nil {
+		return err
+	}
+
+	tagmap := cachedTagValues(val.Type(), config)
+	if err := checkTags(val.Type(), tagmap, config); err != nil {
+		return err
+	}
+
+	return fromMap(m, val, tagmap, config)
+}
+
+// getStructPtrValue returns settable value of a structure given pointer points to
+func getStructPtrValue(dst interface{}) (val reflect.Value, err error) {
+	val = reflect.ValueOf(dst)
+
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		err = fmt.Errorf("provided value is not a non-nil pointer to struct but %T", dst)
+		return
+	}
+
+	val = val.Elem()
+	if val.Kind() != reflect.Struct {
+		err = fmt.Errorf("provided value is not a pointer to struct but pointer to %v", val.Kind())
+	}
+
+	return
+}
+
+// fromMap is a mirror of toMap. Keys that are missing in the map leave fields
+// untouched. Under PolicyUseDefault the default value stands for 'nil', so fields
+// that can hold 'nil' are reset to zero value when the default value is met
+func fromMap(m map[string]interface{}, val reflect.Value, tagmap tags, config Config) error {
+	for _, f := range tagmap.Fields {
+		v, ok := m[f.name]
+		if !ok {
+			continue
+		}
+
+		vField, ok := fieldByIndexAlloc(val, f.index)
+		if !ok {
+			continue
+		}
+
+		if f.policy(config) == PolicyUseDefault && isNillable(vField.Type()) && reflect.DeepEqual(v, f.defaultValue(config)) {
+			v = nil
+		}
+
+		var err error
+		if str, isString := v.(string); isString && f.opts.asString {
+			v, err = unstringify(str, vField.Type())
+		}
+
+		if err == nil {
+			nested, isMap := v.(map[string]interface{})
+			elements, isElements := nestedMaps(v)
+			switch {
+			case f.nested != nil && isMap:
+				err = assignNested(vField, nested, *f.nested, config)
+			case f.nested != nil && isElements:
+				err = assignElements(vField, elements, *f.nested, config)
+			default:
+				err = assignValue(vField, v)
+			}
+		}
+
+		if err != nil {
+			return newFieldError(f, err)
+		}
+	}
+
+	return nil
+}
+
+// fieldByIndexAlloc returns a field by its index sequence, allocating
+// nil pointers to embedded structures along the way. Returns false if a pointer
+// cannot be allocated because it's unexported
+func fieldByIndexAlloc(val reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				if !val.CanSet() {
+					return val, false
+				}
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+
+	return val, true
+}
+
+// assignNested populates a struct field that was converted to nested map
+func assignNested(vField reflect.Value, m map[string]interface{}, tagmap tags, config Config) error {
+	if vField.Kind() == reflect.Ptr {
+		if vField.IsNil() {
+			vField.Set(reflect.New(vField.Type().Elem()))
+		}
+		vField = vField.Elem()
+	}
+
+	return fromMap(m, vField, tagmap, config)
+}
+
+// nestedMaps returns elements of a slice field that was converted to nested maps.
+// Slices of interface{} are accepted too, so maps decoded from JSON can be used
+func nestedMaps(v interface{}) ([]map[string]interface{}, bool) {
+	switch elements := v.(type) {
+	case []map[string]interface{}:
+		return elements, true
+	case []interface{}:
+		maps := make([]map[string]interface{}, len(elements))
+		for i, elem := range elements {
+			if elem == nil {
+				continue
+			}
+			m, ok := elem.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			maps[i] = m
+		}
+		return maps, true
+	}
+
+	return nil, false
+}
+
+// assignElements populates a slice or an array field that was converted to nested maps.
+// Nil maps become zero elements, extra maps are ignored for arrays
+func assignElements(vField reflect.Value, maps []map[string]interface{}, tagmap tags, config Config) error {
+	if vField.Kind() == reflect.Slice {
+		vField.Set(reflect.MakeSlice(vField.Type(), len(maps), len(maps)))
+	} else {
+		vField.Set(reflect.Zero(vField.Type()))
+	}
+
+	for i := 0; i < len(maps) && i < vField.Len(); i++ {
+		if maps[i] == nil {
+			continue
+		}
+
+		if err := assignNested(vField.Index(i), maps[i], tagmap, config); err != nil {
+			return newElementError(i, err)
+		}
+	}
+
+	return nil
+}
+
+// assignValue puts given value into a field, converting it if necessary.
+// sql.Scanner and FromMappable fields are filled using their own methods
+func assignValue(vField reflect.Value, v interface{}) error {
+	if v == nil {
+		vField.Set(reflect.Zero(vField.Type()))
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	typ := vField.Type()
+
+	switch {
+	case rv.Type().AssignableTo(typ):
+		vField.Set(rv)
+		return nil
+	case reflect.PtrTo(typ).Implements(scannerType):
+		return vField.Addr().Interface().(sql.Scanner).Scan(v)
+	case reflect.PtrTo(typ).Implements(fromMappableType):
+		if nested, ok := v.(map[string]interface{}); ok {
+			return vField.Addr().Interface().(FromMappable).FromMap(nested)
+		}
+	case typ.Kind() == reflect.Ptr:
+		elem := reflect.New(typ.Elem())
+		if err := assignValue(elem.Elem(), v); err != nil {
+			return err
+		}
+		vField.Set(elem)
+		return nil
+	case isConvertible(rv.Type(), typ):
+		if err := checkNumber(rv, typ); err != nil {
+			return err
+		}
+		vField.Set(rv.Convert(typ))
+		return nil
+	}
+
+	return fmt.Errorf("value of type %T is not assignable to %s", v, typ)
+}
+
+// isConvertible checks whether a value can be converted without changing its meaning,
+// e.g. int64 coming from database driver can be put into int field,
+// but int cannot become a string
+func isConvertible(from, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+
+	return from.Kind() == to.Kind() || (isNumber(from.Kind()) && isNumber(to.Kind()))
+}
+
+// checkNumber checks that a number fits into given numeric type as is:
+// it doesn't overflow and it has no fractional part if the type is an integer
+func checkNumber(rv reflect.Value, typ reflect.Type) error {
+	if !isNumber(rv.Kind()) || !isNumber(typ.Kind()) {
+		return nil
+	}
+
+	target := reflect.Zero(typ)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch {
+		case isFloat(typ.Kind()):
+			if target.OverflowFloat(f) {
+				return fmt.Errorf("value %v overflows %s", f, typ)
+			}
+			return nil
+		case f != math.Trunc(f):
+			return fmt.Errorf("value %v is not an integer", f)
+		case f < math.MinInt64 || f >= math.MaxUint64 || (isSigned(typ.Kind()) && f >= math.MaxInt64) || (!isSigned(typ.Kind()) && f < 0):
+			return fmt.Errorf("value %v overflows %s", f, typ)
+		}
+		if isSigned(typ.Kind()) {
+			rv = reflect.ValueOf(int64(f))
+		} else {
+			rv = reflect.ValueOf(uint64(f))
+		}
+	}
+
+	switch {
+	case isFloat(typ.Kind()):
+		return nil
+	case isSigned(rv.Kind()) && isSigned(typ.Kind()):
+		if target.OverflowInt(rv.Int()) {
+			return fmt.Errorf("value %v overflows %s", rv.Int(), typ)
+		}
+	case isSigned(rv.Kind()):
+		if rv.Int() < 0 || target.OverflowUint(uint64(rv.Int())) {
+			return fmt.Errorf("value %v overflows %s", rv.Int(), typ)
+		}
+	case isSigned(typ.Kind()):
+		if rv.Uint() > math.MaxInt64 || target.OverflowInt(int64(rv.Uint())) {
+			return fmt.Errorf("value %v overflows %s", rv.Uint(), typ)
+		}
+	default:
+		if target.OverflowUint(rv.Uint()) {
+			return fmt.Errorf("value %v overflows %s", rv.Uint(), typ)
+		}
+	}
+
+	return nil
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isSigned(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+
+	return false
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// isNillable checks whether filterValue may consider a value of given type as 'nil'
+func isNillable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return true
+	}
+
+	return typ.Implements(valuerType) || typ.Implements(zeroableType)
+}
+
+// unstringify parses value of a field with 'string' option back to a basic type.
+// Values of other types are left as strings to be handled by assignValue
+func unstringify(str string, typ reflect.Type) (interface{}, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(str, 10, typ.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(str, 10, typ.Bits())
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(str, typ.Bits())
+	}
+
+	return str, nil
+}
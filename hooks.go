@@ -0,0 +1,173 @@
+package stom
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Hook identifies an interface that SToM recognizes in values of fields
+// and the way such values are converted
+type Hook uint8
+
+const (
+	// HookValuer treats driver.Valuer values returning nil or an error as 'nil',
+	// see also Config.WithUnwrapValuer
+	HookValuer Hook = iota
+
+	// HookZeroable treats Zeroable values with IsZero() returning true as 'nil'
+	HookZeroable
+
+	// HookToMappable converts ToMappableWith and ToMappable values with
+	// their ToMapWith() and ToMap() methods
+	HookToMappable
+
+	// HookTextMarshaler converts encoding.TextMarshaler values to strings
+	HookTextMarshaler
+
+	// HookJSONRaw converts json.Marshaler values to json.RawMessage
+	HookJSONRaw
+
+	// HookJSONValue converts json.Marshaler values to values their JSON
+	// is decoded into, e.g. map[string]interface{} or float64
+	HookJSONValue
+
+	// HookStringer converts fmt.Stringer values to strings
+	HookStringer
+)
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// hookTypes are interfaces recognized by hooks
+var hookTypes = map[Hook][]reflect.Type{
+	HookValuer:        {valuerType},
+	HookZeroable:      {zeroableType},
+	HookToMappable:    {toMappableWithType, toMappableType},
+	HookTextMarshaler: {textMarshalerType},
+	HookJSONRaw:       {jsonMarshalerType},
+	HookJSONValue:     {jsonMarshalerType},
+	HookStringer:      {stringerType},
+}
+
+// DefaultHooks returns hooks that are used by default, in order of precedence:
+// HookValuer, HookZeroable and HookToMappable
+func DefaultHooks() []Hook {
+	return []Hook{HookValuer, HookZeroable, HookToMappable}
+}
+
+// encodeHooks packs hooks into comparable string, unknown hooks are dropped
+func encodeHooks(hooks []Hook) string {
+	encoded := make([]byte, 0, len(hooks))
+	for _, hook := range hooks {
+		if _, ok := hookTypes[hook]; ok {
+			encoded = append(encoded, byte(hook))
+		}
+	}
+
+	return string(encoded)
+}
+
+// decodeHooks unpacks hooks packed with encodeHooks
+func decodeHooks(encoded string) []Hook {
+	hooks := make([]Hook, len(encoded))
+	for i := 0; i < len(encoded); i++ {
+		hooks[i] = Hook(encoded[i])
+	}
+
+	return hooks
+}
+
+// applyHooks converts a value with the first hook it's recognized by.
+// ref is a pointer to the value, if any, so methods with pointer receivers are recognized too.
+// Values that are not recognized by any hook are returned as is
+func applyHooks(v, ref interface{}, config Config) (interface{}, error) {
+	for i := 0; i < len(config.hooks); i++ {
+		for _, target := range []interface{}{v, ref} {
+			if target == nil {
+				continue
+			}
+			if converted, ok, err := applyHook(Hook(config.hooks[i]), v, target, config); ok {
+				return converted, err
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// applyHook converts a value with given hook, methods of target are used to convert it.
+// Returns false if the hook doesn't recognize the target
+func applyHook(hook Hook, v, target interface{}, config Config) (interface{}, bool, error) {
+	switch hook {
+	case HookValuer:
+		if t, ok := target.(driver.Valuer); ok { // support for NullTypes like sql.NullString and so on
+			converted, err := t.Value()
+			switch {
+			case err != nil && config.unwrapValuer:
+				return nil, true, err
+			case err != nil || converted == nil:
+				return nil, true, nil
+			case config.unwrapValuer:
+				return converted, true, nil
+			}
+			return v, true, nil
+		}
+	case HookZeroable:
+		if t, ok := target.(Zeroable); ok {
+			if t.IsZero() {
+				return nil, true, nil
+			}
+			return v, true, nil
+		}
+	case HookToMappable:
+		if t, ok := target.(ToMappableWith); ok {
+			m, err := t.ToMapWith(config)
+			return m, true, err
+		}
+		if t, ok := target.(ToMappable); ok {
+			m, err := t.ToMap()
+			return m, true, err
+		}
+	case HookTextMarshaler:
+		if t, ok := target.(encoding.TextMarshaler); ok {
+			text, err := t.MarshalText()
+			return string(text), true, err
+		}
+	case HookJSONRaw:
+		if t, ok := target.(json.Marshaler); ok {
+			raw, err := t.MarshalJSON()
+			return json.RawMessage(raw), true, err
+		}
+	case HookJSONValue:
+		if t, ok := target.(json.Marshaler); ok {
+			raw, err := t.MarshalJSON()
+			if err != nil {
+				return nil, true, err
+			}
+			var decoded interface{}
+			err = json.Unmarshal(raw, &decoded)
+			return decoded, true, err
+		}
+	case HookStringer:
+		if t, ok := target.(fmt.Stringer); ok {
+			return t.String(), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// implementsHooks checks whether given type or pointer to it is recognized by some of given hooks
+func implementsHooks(typ reflect.Type, hooks string) bool {
+	for i := 0; i < len(hooks); i++ {
+		for _, iface := range hookTypes[Hook(hooks[i])] {
+			if typ.Implements(iface) || (typ.Kind() != reflect.Ptr && reflect.PtrTo(typ).Implements(iface)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
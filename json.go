@@ -0,0 +1,362 @@
+package stom
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonTagRules adjusts results of lookupTag to rules of encoding/json:
+// only 'omitempty' and 'string' options are known, invalid keys are replaced
+// by names of fields and only fields with keys in tags are considered tagged
+func jsonTagRules(structField reflect.StructField, name string, opts tagOptions, tagged bool) (string, tagOptions, bool) {
+	if !isValidJSONKey(name) {
+		name = ""
+	}
+
+	opts = tagOptions{
+		omitEmpty: opts.omitEmpty,
+		asString:  opts.asString && isJSONQuotable(structField.Type),
+	}
+
+	return name, opts, tagged && name != ""
+}
+
+// isValidJSONKey checks whether encoding/json accepts given key from a tag
+func isValidJSONKey(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, c := range name {
+		if !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isJSONQuotable checks whether 'string' option applies to a field of given type
+func isJSONQuotable(typ reflect.Type) bool {
+	if typ.Name() == "" && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// jsonToMap converts a structure in encoding/json compatibility mode
+func jsonToMap(obj interface{}, config Config) (map[string]interface{}, error) {
+	e := jsonEncoder{config: config, seen: map[jsonVisit]bool{}}
+
+	v, err := e.value(reflect.ValueOf(obj), false)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("json representation of %T is not an object", obj)
+	}
+
+	return m, nil
+}
+
+// jsonVisit identifies a pointer or a map being converted
+type jsonVisit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// jsonEncoder converts values to what json.Unmarshal of their json.Marshal
+// representation into interface{} would give: numbers become float64,
+// structures and maps become map[string]interface{}, slices and arrays
+// become []interface{}
+type jsonEncoder struct {
+	config Config
+	// seen holds pointers and maps being converted to detect cycles
+	seen map[jsonVisit]bool
+}
+
+func (e *jsonEncoder) value(v reflect.Value, quoted bool) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if m, ok, err := marshaled(v); ok {
+		return m, err
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if quoted {
+			return strconv.FormatBool(v.Bool()), nil
+		}
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if quoted {
+			return strconv.FormatInt(v.Int(), 10), nil
+		}
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if quoted {
+			return strconv.FormatUint(v.Uint(), 10), nil
+		}
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return jsonFloat(v.Float(), v.Type().Bits(), quoted)
+	case reflect.String:
+		if quoted {
+			b, err := json.Marshal(v.String())
+			return string(b), err
+		}
+		return jsonString(v.String()), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return e.value(v.Elem(), false)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if err := e.enter(v); err != nil {
+			return nil, err
+		}
+		defer e.leave(v)
+		return e.value(v.Elem(), quoted)
+	case reflect.Struct:
+		return e.fields(v, cachedTagValues(v.Type(), e.config))
+	case reflect.Map:
+		return e.mapValue(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if isJSONBytes(v.Type()) {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		return e.array(v)
+	case reflect.Array:
+		return e.array(v)
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// fields converts fields of a structure
+func (e *jsonEncoder) fields(val reflect.Value, tagmap tags) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(tagmap.Fields))
+
+	for _, f := range tagmap.Fields {
+		vField, ok := fieldByIndex(val, f.index)
+		if !ok || (f.opts.omitEmpty && isEmptyValue(vField)) {
+			continue
+		}
+
+		v, err := e.value(vField, f.opts.asString)
+		if err != nil {
+			return nil, newFieldError(f, err)
+		}
+
+		result[f.name] = v
+	}
+
+	return result, nil
+}
+
+// marshaled converts values implementing json.Marshaler or encoding.TextMarshaler,
+// methods of addressable values with pointer receivers are used too.
+// Returns false if the value implements none of them
+func marshaled(v reflect.Value) (interface{}, bool, error) {
+	if v.Kind() == reflect.Interface || !v.CanInterface() {
+		return nil, false, nil
+	}
+
+	for _, iface := range []reflect.Type{jsonMarshalerType, textMarshalerType} {
+		switch {
+		case v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(iface):
+			m, err := marshal(v.Addr())
+			return m, true, err
+		case v.Type().Implements(iface):
+			m, err := marshal(v)
+			return m, true, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// marshal calls MarshalJSON or MarshalText method of given value
+func marshal(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+
+	switch marshaler := v.Interface().(type) {
+	case json.Marshaler:
+		b, err := marshaler.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var result interface{}
+		err = json.Unmarshal(b, &result)
+		return result, err
+	case encoding.TextMarshaler:
+		text, err := marshaler.MarshalText()
+		return jsonString(string(text)), err
+	}
+
+	return nil, nil
+}
+
+// mapValue converts a map, its keys must be strings, integers or implement encoding.TextMarshaler
+func (e *jsonEncoder) mapValue(v reflect.Value) (interface{}, error) {
+	if v.IsNil() {
+		return nil, nil
+	}
+
+	if err := e.enter(v); err != nil {
+		return nil, err
+	}
+	defer e.leave(v)
+
+	result := make(map[string]interface{}, v.Len())
+
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := jsonKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+
+		result[key], err = e.value(iter.Value(), false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (e *jsonEncoder) array(v reflect.Value) (interface{}, error) {
+	result := make([]interface{}, v.Len())
+
+	for i := range result {
+		var err error
+		if result[i], err = e.value(v.Index(i), false); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// enter marks a pointer or a map as being converted, returns an error on a cycle
+func (e *jsonEncoder) enter(v reflect.Value) error {
+	visit := jsonVisit{ptr: v.Pointer(), typ: v.Type()}
+	if e.seen[visit] {
+		return fmt.Errorf("encountered a cycle via %s", v.Type())
+	}
+	e.seen[visit] = true
+
+	return nil
+}
+
+func (e *jsonEncoder) leave(v reflect.Value) {
+	delete(e.seen, jsonVisit{ptr: v.Pointer(), typ: v.Type()})
+}
+
+// jsonKey converts a key of a map to string
+func jsonKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return jsonString(k.String()), nil
+	}
+
+	if k.Type().Implements(textMarshalerType) && k.CanInterface() {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return jsonString(string(text)), err
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+
+	return "", fmt.Errorf("unsupported type %s", k.Type())
+}
+
+// jsonFloat converts a float the way it survives encoding to JSON,
+// e.g. float32(0.1) becomes float64(0.1), not float64(0.10000000149011612)
+func jsonFloat(f float64, bits int, quoted bool) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported value %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+
+	if quoted {
+		var b []byte
+		var err error
+		if bits == 32 {
+			b, err = json.Marshal(float32(f))
+		} else {
+			b, err = json.Marshal(f)
+		}
+		return string(b), err
+	}
+
+	if bits == 32 {
+		return strconv.ParseFloat(strconv.FormatFloat(f, 'g', -1, 32), 64)
+	}
+
+	return f, nil
+}
+
+// jsonString replaces every byte of invalid UTF-8 sequences with U+FFFD
+// as encoding/json does
+func jsonString(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var b strings.Builder
+	for _, c := range s {
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// isJSONBytes checks whether encoding/json encodes slice of given type as base64 string
+func isJSONBytes(typ reflect.Type) bool {
+	elem := reflect.PtrTo(typ.Elem())
+
+	return typ.Elem().Kind() == reflect.Uint8 &&
+		!elem.Implements(jsonMarshalerType) &&
+		!elem.Implements(textMarshalerType)
+}
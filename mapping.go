@@ -0,0 +1,368 @@
+package stom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Mapping describes keys of fields of a structure programmatically.
+// It allows to convert types that cannot be tagged, e.g. types of third-party packages.
+// Fields are referenced by Go paths, e.g. "Name" or "Address.City"
+//
+//	mapping := stom.NewMapping().
+//		Field("ID", "id").
+//		Field("Address.City", "city", "omitempty").
+//		Policy("DeletedAt", stom.PolicyExclude).
+//		Skip("Password")
+type Mapping struct {
+	paths  []string
+	fields map[string]mappedField
+}
+
+// mappedField is a mapping of a single field
+type mappedField struct {
+	// mapped tells whether key is set, otherwise it comes from tags
+	mapped bool
+	key    string
+	// optioned tells whether options are set, otherwise they come from tags
+	optioned bool
+	options  []string
+	skip     bool
+	policy   *Policy
+}
+
+// NewMapping creates an empty Mapping
+func NewMapping() *Mapping {
+	return &Mapping{fields: map[string]mappedField{}}
+}
+
+func (m *Mapping) update(path string, update func(f *mappedField)) *Mapping {
+	f, ok := m.fields[path]
+	if !ok {
+		m.paths = append(m.paths, path)
+	}
+	update(&f)
+	m.fields[path] = f
+
+	return m
+}
+
+// Field maps a field to given key with given tag options like "omitempty" or "string".
+// Empty key stands for the name of the field
+func (m *Mapping) Field(path, key string, options ...string) *Mapping {
+	return m.update(path, func(f *mappedField) {
+		f.mapped, f.optioned, f.skip = true, true, false
+		f.key, f.options = key, options
+	})
+}
+
+// Options sets tag options of a field like "omitempty" or "string". The key of the field
+// still comes from tags unless it's mapped with Field
+func (m *Mapping) Options(path string, options ...string) *Mapping {
+	return m.update(path, func(f *mappedField) {
+		f.optioned = true
+		f.options = options
+	})
+}
+
+// Skip excludes a field from conversions
+func (m *Mapping) Skip(path string) *Mapping {
+	return m.update(path, func(f *mappedField) {
+		f.skip = true
+	})
+}
+
+// Policy sets policy for 'nil' values of a field. The key of the field
+// still comes from tags unless it's mapped with Field
+func (m *Mapping) Policy(path string, policy Policy) *Mapping {
+	return m.update(path, func(f *mappedField) {
+		f.policy = &policy
+	})
+}
+
+// fieldMappings are mappings of fields by paths relative to a structure
+type fieldMappings map[string]mappedField
+
+// sub returns mappings of fields of given struct field
+func (m fieldMappings) sub(name string) fieldMappings {
+	var sub fieldMappings
+	for path, f := range m {
+		if strings.HasPrefix(path, name+".") {
+			if sub == nil {
+				sub = fieldMappings{}
+			}
+			sub[strings.TrimPrefix(path, name+".")] = f
+		}
+	}
+
+	return sub
+}
+
+// merge returns mappings of both sets, the outer ones win
+func (m fieldMappings) merge(outer fieldMappings) fieldMappings {
+	if len(outer) == 0 {
+		return m
+	}
+
+	merged := fieldMappings{}
+	for path, f := range m {
+		merged[path] = f
+	}
+	for path, f := range outer {
+		merged[path] = f
+	}
+
+	return merged
+}
+
+// apply overrides results of lookupTag with the mapping
+func (f mappedField) apply(name string, opts tagOptions, found, tagged, skip bool) (string, tagOptions, bool, bool, bool) {
+	if f.mapped {
+		name, found, tagged, skip = f.key, true, true, false
+	}
+
+	if f.optioned {
+		opts = parseOptions(f.options)
+		if !found {
+			// untagged field gets its name as a key
+			name, found, tagged = "", true, true
+		}
+	}
+
+	if f.skip {
+		skip = true
+	}
+
+	if f.policy != nil {
+		opts.policy = f.policy
+	}
+
+	return name, opts, found, tagged, skip
+}
+
+// Registry of mappings and of types known to LoadMappings by names
+var (
+	mappingsMutex sync.RWMutex
+	mappings      = map[reflect.Type]fieldMappings{}
+	namedTypes    = map[string]reflect.Type{}
+)
+
+// Register makes all conversions use given mapping for type of given structure.
+// Mapping is consulted before tags, fields that are not mapped are converted as usual.
+// It applies to the type wherever it appears: embedded, flattened or nested.
+// Returns *MappingError if the mapping refers to fields that don't exist or are unexported.
+// SToMs created before the registration are not affected
+func Register(s interface{}, mapping *Mapping) error {
+	typ, err := getStructType(s)
+	if err != nil {
+		return err
+	}
+
+	resolved, errs := resolveMapping(typ, mapping)
+	if len(errs) > 0 {
+		return &MappingError{Errors: errs}
+	}
+
+	register(map[reflect.Type]fieldMappings{typ: resolved})
+
+	return nil
+}
+
+// resolveMapping checks all paths of the mapping and makes them full
+func resolveMapping(typ reflect.Type, mapping *Mapping) (fieldMappings, []error) {
+	var errs []error
+
+	resolved := fieldMappings{}
+	for _, path := range mapping.paths {
+		goPath, err := resolvePath(typ, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resolved[goPath] = mapping.fields[path]
+	}
+
+	return resolved, errs
+}
+
+func register(resolved map[reflect.Type]fieldMappings) {
+	mappingsMutex.Lock()
+	for typ, mapping := range resolved {
+		mappings[typ] = mapping
+	}
+	mappingsMutex.Unlock()
+
+	ResetCache()
+}
+
+// Unregister drops mapping of type of given structure
+func Unregister(s interface{}) {
+	typ, err := getStructType(s)
+	if err != nil {
+		return
+	}
+
+	mappingsMutex.Lock()
+	delete(mappings, typ)
+	mappingsMutex.Unlock()
+
+	ResetCache()
+}
+
+func registeredMappings(typ reflect.Type) fieldMappings {
+	mappingsMutex.RLock()
+	defer mappingsMutex.RUnlock()
+
+	return mappings[typ]
+}
+
+// resolvePath checks that a path refers to an exported field of given type and
+// returns full Go path to the field, so fields promoted from embedded structures
+// can be referenced by their names
+func resolvePath(typ reflect.Type, path string) (string, error) {
+	var goPath []string
+
+	current := typ
+	for _, name := range strings.Split(path, ".") {
+		current = derefType(current)
+		if current.Kind() != reflect.Struct {
+			return "", fmt.Errorf("field %s of type %s is not found: %s is not a struct", path, typ, current)
+		}
+
+		structField, ok := current.FieldByName(name)
+		if !ok {
+			return "", fmt.Errorf("field %s of type %s is not found", path, typ)
+		}
+		if structField.PkgPath != "" && !structField.Anonymous {
+			return "", fmt.Errorf("field %s of type %s is unexported", path, typ)
+		}
+
+		for i := range structField.Index {
+			goPath = append(goPath, current.FieldByIndex(structField.Index[:i+1]).Name)
+		}
+		current = structField.Type
+	}
+
+	return strings.Join(goPath, "."), nil
+}
+
+// RegisterType makes type of given structure known to LoadMappings under given name
+func RegisterType(name string, s interface{}) error {
+	typ, err := getStructType(s)
+	if err != nil {
+		return err
+	}
+
+	mappingsMutex.Lock()
+	namedTypes[name] = typ
+	mappingsMutex.Unlock()
+
+	return nil
+}
+
+// mappingDefinition is a mapping of a type in a JSON document
+type mappingDefinition struct {
+	Type   string            `json:"type"`
+	Fields []fieldDefinition `json:"fields"`
+}
+
+// fieldDefinition is a mapping of a field in a JSON document
+type fieldDefinition struct {
+	Path    string   `json:"path"`
+	Key     *string  `json:"key"`
+	Options []string `json:"options"`
+	Skip    bool     `json:"skip"`
+	Policy  string   `json:"policy"`
+}
+
+// LoadMappings reads mappings from a JSON document and registers them.
+// Types are referenced by names given to RegisterType:
+//
+//	[{
+//		"type": "user",
+//		"fields": [
+//			{"path": "Name", "key": "user_name", "options": ["omitempty"]},
+//			{"path": "Email", "policy": "exclude"},
+//			{"path": "Password", "skip": true}
+//		]
+//	}]
+//
+// Fields with options but without key keep keys from tags.
+// Policies are referenced as "default", "exclude", "omitzero", "error" and "keepnil". All definitions are
+// validated before registration, *MappingError lists all unknown types, fields
+// and policies at once, nothing is registered in this case
+func LoadMappings(r io.Reader) error {
+	var definitions []mappingDefinition
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definitions); err != nil {
+		return fmt.Errorf("cannot decode mappings: %s", err.Error())
+	}
+
+	var errs []error
+	resolved := map[reflect.Type]fieldMappings{}
+	for _, definition := range definitions {
+		mappingsMutex.RLock()
+		typ, ok := namedTypes[definition.Type]
+		mappingsMutex.RUnlock()
+
+		if !ok {
+			errs = append(errs, fmt.Errorf("type %s is not registered", definition.Type))
+			continue
+		}
+
+		mapping, policyErrs := definition.mapping()
+		errs = append(errs, policyErrs...)
+
+		fields, pathErrs := resolveMapping(typ, mapping)
+		errs = append(errs, pathErrs...)
+
+		resolved[typ] = resolved[typ].merge(fields)
+	}
+
+	if len(errs) > 0 {
+		return &MappingError{Errors: errs}
+	}
+
+	register(resolved)
+
+	return nil
+}
+
+// mapping builds Mapping from the definition
+func (d mappingDefinition) mapping() (*Mapping, []error) {
+	var errs []error
+
+	mapping := NewMapping()
+	for _, f := range d.Fields {
+		// every path is validated, even if nothing is mapped
+		mapping.update(f.Path, func(*mappedField) {})
+
+		switch {
+		case f.Key != nil:
+			mapping.Field(f.Path, *f.Key, f.Options...)
+		case f.Options != nil:
+			mapping.Options(f.Path, f.Options...)
+		}
+
+		if f.Skip {
+			mapping.Skip(f.Path)
+		}
+
+		if f.Policy != "" {
+			policy, ok := policyNames[f.Policy]
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown policy %s of field %s of type %s", f.Policy, f.Path, d.Type))
+				continue
+			}
+			mapping.Policy(f.Path, policy)
+		}
+	}
+
+	return mapping, errs
+}
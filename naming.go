@@ -0,0 +1,116 @@
+package stom
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NamingStrategy converts name of an untagged field to a key
+type NamingStrategy interface {
+	Name(fieldName string) string
+}
+
+// caseNaming is a built-in naming strategy
+type caseNaming uint8
+
+const (
+	snakeCase caseNaming = iota
+	camelCase
+	kebabCase
+	lowerCase
+)
+
+// Built-in naming strategies. Acronyms are treated as single words,
+// e.g. "UserID" becomes "user_id" in snake case
+var (
+	// SnakeCase names fields like "user_id"
+	SnakeCase NamingStrategy = snakeCase
+	// CamelCase names fields like "userId"
+	CamelCase NamingStrategy = camelCase
+	// KebabCase names fields like "user-id"
+	KebabCase NamingStrategy = kebabCase
+	// LowerCase names fields like "userid"
+	LowerCase NamingStrategy = lowerCase
+)
+
+// Name implements NamingStrategy
+func (n caseNaming) Name(fieldName string) string {
+	switch n {
+	case snakeCase:
+		return strings.ToLower(strings.Join(splitWords(fieldName), "_"))
+	case kebabCase:
+		return strings.ToLower(strings.Join(splitWords(fieldName), "-"))
+	case camelCase:
+		words := splitWords(fieldName)
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 {
+				first, size := utf8.DecodeRuneInString(word)
+				word = string(unicode.ToUpper(first)) + word[size:]
+			}
+			words[i] = word
+		}
+		return strings.Join(words, "")
+	}
+
+	return strings.ToLower(fieldName)
+}
+
+// funcNaming adapts a function to NamingStrategy.
+// It's used by pointer, so analysis of types is cached per strategy
+type funcNaming struct {
+	fn func(fieldName string) string
+}
+
+// Name implements NamingStrategy
+func (n *funcNaming) Name(fieldName string) string {
+	return n.fn(fieldName)
+}
+
+// NamingFunc makes NamingStrategy from given function.
+// Analysis of types is cached per returned strategy, so create it once
+// and reuse it for repeated conversions
+func NamingFunc(fn func(fieldName string) string) NamingStrategy {
+	return &funcNaming{fn: fn}
+}
+
+// splitWords splits a name of a field into words, keeping acronyms together:
+// "HTTPServerID" becomes "HTTP", "Server", "ID". Trailing "s" of an acronym
+// is kept with it, so "UserIDs" becomes "User", "IDs"
+func splitWords(name string) []string {
+	runes := []rune(name)
+	words := []string{}
+
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		lowerToUpper := !unicode.IsUpper(prev) && unicode.IsUpper(cur)
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1]) && !isPluralEnd(runes, i+1)
+
+		if cur == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+
+		if (lowerToUpper || acronymEnd) && i > start {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+// isPluralEnd checks whether a rune at given position is "s" ending a word, like in "IDs"
+func isPluralEnd(runes []rune, i int) bool {
+	return runes[i] == 's' && (i+1 == len(runes) || !unicode.IsLower(runes[i+1]))
+}
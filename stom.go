@@ -4,8 +4,10 @@ package stom
 
 import (
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 )
 
 // Policy is a type to define policy of dealing with 'nil' values
@@ -20,14 +22,64 @@ const (
 	// PolicyExclude tells SToM to ignore 'nil' values and to not include them in
 	// resulting map
 	PolicyExclude
+
+	// PolicyOmitZero tells SToM to ignore 'nil' values along with any zero values,
+	// e.g. 0, "", false, empty slices and maps or zero structures
+	PolicyOmitZero
+
+	// PolicyError makes conversion fail with ErrNilValue wrapped in *FieldError
+	// if a 'nil' value is met
+	PolicyError
+
+	// PolicyKeepNil puts literal 'nil' into resulting map regardless of default value
+	PolicyKeepNil
 )
 
-// Package settings
-// They are used as defaults for initialization if new SToMs
-var (
-	tagSetting          = "db"
-	policySetting       = PolicyUseDefault
-	defaultValueSetting interface{}
+// ErrNilValue is returned under PolicyError if a field has 'nil' value
+var ErrNilValue = errors.New("value is nil")
+
+// policyNames are names of policies in tag options and mapping definitions
+var policyNames = map[string]Policy{
+	"default":  PolicyUseDefault,
+	"exclude":  PolicyExclude,
+	"omitzero": PolicyOmitZero,
+	"error":    PolicyError,
+	"keepnil":  PolicyKeepNil,
+}
+
+// StructMode is a type to define how to deal with named (not embedded) fields
+// of struct types
+type StructMode uint8
+
+const (
+	// StructAsValue puts value of struct field into resulting map as is
+	StructAsValue StructMode = iota
+
+	// StructNest converts struct field to nested map[string]interface{}
+	StructNest
+
+	// StructFlatten flattens fields of struct field into resulting map,
+	// their keys are prefixed with key of the struct field and separator,
+	// e.g. "address.city"
+	StructFlatten
+)
+
+// SliceMode is a type to define how to deal with named fields of slice
+// and array types with struct elements
+type SliceMode uint8
+
+const (
+	// SliceAsValue puts value of slice field into resulting map as is
+	SliceAsValue SliceMode = iota
+
+	// SliceNest converts elements of slice field to nested maps,
+	// so the value becomes []map[string]interface{}
+	SliceNest
+
+	// SliceFlatten flattens fields of elements of slice field into resulting map,
+	// their keys are prefixed with key of the slice field and index of an element,
+	// e.g. "items.0.name" or "items[0].name", see Config.WithIndexBrackets
+	SliceFlatten
 )
 
 // Zeroable is an interface that allows to filter values that can explicitly
@@ -45,6 +97,21 @@ type ToMappable interface {
 	ToMap() (map[string]interface{}, error)
 }
 
+// ToMappableWith defines an entity that converts itself to map[string]interface{}
+// depending on settings of the conversion. It goes before ToMappable and allows
+// custom conversion to extend the default one with the same settings:
+//
+//	func (i Item) ToMapWith(config stom.Config) (map[string]interface{}, error) {
+//		m, err := stom.DefaultWith(i, config)
+//		if err == nil {
+//			m["computed"] = i.Compute()
+//		}
+//		return m, err
+//	}
+type ToMappableWith interface {
+	ToMapWith(config Config) (map[string]interface{}, error)
+}
+
 // ToMapper defines a service that is able to convert something to map[string]interface{}
 type ToMapper interface {
 	ToMap(s interface{}) (map[string]interface{}, error)
@@ -58,101 +125,351 @@ func (f ToMapperFunc) ToMap(s interface{}) (map[string]interface{}, error) {
 	return f(s)
 }
 
-type tags struct {
-	Simple map[int]string
-	Nested map[int]tags
+// Stom is a small handy tool that is instantiated for certain type and caches
+// all knowledge about this type to increase conversion speed
+type Stom struct {
+	config Config
+
+	typ       reflect.Type
+	cache     tags
+	tagValues []string
+	err       error
 }
 
-func (t tags) TagsList() []string {
-	tagsList := []string{}
-	tagsMap := map[string]interface{}{}
-	for _, tagName := range t.Simple {
-		tagsMap[tagName] = nil
-	}
+// NewStom creates new instance of a SToM converter for type of given structure
+// with package settings.
+// Returns an error if no structure provided, if structure has invalid tag options
+// or duplicate keys in strict mode
+func NewStom(s interface{}) (*Stom, error) {
+	return NewStomWith(s, PackageConfig())
+}
 
-	for _, nestedTags := range t.Nested {
-		nestedTagsList := nestedTags.TagsList()
-		for _, tagName := range nestedTagsList {
-			tagsMap[tagName] = nil
-		}
+// NewStomWith creates new instance of a SToM converter for type of given structure
+// with given settings.
+// Returns an error if no structure provided, if structure has invalid tag options
+// or duplicate keys in strict mode
+func NewStomWith(s interface{}, config Config) (*Stom, error) {
+	typ, err := getStructType(s)
+	if err != nil {
+		return nil, err
 	}
 
-	for tagName := range tagsMap {
-		tagsList = append(tagsList, tagName)
-	}
+	return newStom(typ, config)
+}
 
-	return tagsList
+// MustNewStom creates new instance of a SToM converter for type of given structure
+// with package settings.
+// Panics if no structure provided, if structure has invalid tag options
+// or duplicate keys in strict mode
+func MustNewStom(s interface{}) *Stom {
+	return MustNewStomWith(s, PackageConfig())
 }
 
-func newTags() tags {
-	return tags{
-		Simple: make(map[int]string),
-		Nested: make(map[int]tags),
+// MustNewStomWith creates new instance of a SToM converter for type of given structure
+// with given settings.
+// Panics if no structure provided, if structure has invalid tag options
+// or duplicate keys in strict mode
+func MustNewStomWith(s interface{}, config Config) *Stom {
+	converter, err := NewStomWith(s, config)
+	if err != nil {
+		panic(err.Error())
 	}
+
+	return converter
 }
 
-// stom is a small handy tool that is instantiated for certain type and caches
-// all knowledge about this type to increase conversion speed
-type stom struct {
-	defaultValue interface{}
-	policy       Policy
-	tag          string
+// newStom creates new instance of a SToM converter for given struct type
+func newStom(typ reflect.Type, config Config) (*Stom, error) {
+	s := &Stom{
+		typ:    typ,
+		config: config,
+	}
+	s.scan()
 
-	typ       reflect.Type
-	cache     tags
-	tagValues []string
+	return s, s.err
 }
 
-// MustNewStom creates new instance of a SToM converter for type of given structure.
-// Panics if no structure provided
-func MustNewStom(s interface{}) *stom {
-	typ, err := getStructType(s)
-	if err != nil {
-		panic(err.Error())
+// WithConfig derives a new SToM for the same type with given settings.
+// The original SToM is not modified. Analysis of the type is shared
+// between SToMs with the same tag and struct settings
+func (s *Stom) WithConfig(config Config) *Stom {
+	derived := &Stom{
+		typ:       s.typ,
+		config:    config,
+		cache:     s.cache,
+		tagValues: s.tagValues,
 	}
 
-	stom := &stom{
-		typ:          typ,
-		defaultValue: defaultValueSetting,
-		policy:       policySetting,
+	if !config.scanConfig.equal(s.config.scanConfig) {
+		derived.scan()
+	} else {
+		derived.err = checkTags(s.typ, s.cache, config)
 	}
-	stom.SetTag(tagSetting)
 
-	return stom
+	return derived
 }
 
-// SetTag sets SToM to scan for given tag in structure
-func (s *stom) SetTag(tag string) *stom {
-	s.tag = tag
-	s.cache = extractTagValues(s.typ, s.tag)
-	s.tagValues = s.cache.TagsList()
+// WithTag derives a new SToM that scans for given tag in structure
+func (s *Stom) WithTag(tag string) *Stom {
+	return s.WithConfig(s.config.WithTag(tag))
+}
+
+// WithTags derives a new SToM that scans for given chain of tags in structure
+func (s *Stom) WithTags(tags ...string) *Stom {
+	return s.WithConfig(s.config.WithTags(tags...))
+}
+
+// WithValueConverter derives a new SToM that converts values of given type with given converter
+func (s *Stom) WithValueConverter(typ reflect.Type, converter ValueConverter) *Stom {
+	return s.WithConfig(s.config.WithValueConverter(typ, converter))
+}
+
+// WithUnwrapValuer derives a new SToM that puts results of Value() of driver.Valuer
+// values into resulting map instead of the values themselves
+func (s *Stom) WithUnwrapValuer(unwrap bool) *Stom {
+	return s.WithConfig(s.config.WithUnwrapValuer(unwrap))
+}
+
+// WithHooks derives a new SToM that recognizes values of fields with given hooks
+func (s *Stom) WithHooks(hooks ...Hook) *Stom {
+	return s.WithConfig(s.config.WithHooks(hooks...))
+}
+
+// WithTagParsers derives a new SToM that recognizes fields with given chain of parsers
+func (s *Stom) WithTagParsers(parsers ...TagParser) *Stom {
+	return s.WithConfig(s.config.WithTagParsers(parsers...))
+}
+
+// WithPolicy derives a new SToM with given policy for 'nil' values
+func (s *Stom) WithPolicy(policy Policy) *Stom {
+	return s.WithConfig(s.config.WithPolicy(policy))
+}
+
+// WithDefault derives a new SToM that puts given default value in 'nil' values
+func (s *Stom) WithDefault(defaultValue interface{}) *Stom {
+	return s.WithConfig(s.config.WithDefault(defaultValue))
+}
+
+// WithStructMode derives a new SToM with given mode for named fields of struct types
+func (s *Stom) WithStructMode(mode StructMode) *Stom {
+	return s.WithConfig(s.config.WithStructMode(mode))
+}
+
+// WithSliceMode derives a new SToM with given mode for named fields of slice and array types with struct elements
+func (s *Stom) WithSliceMode(mode SliceMode) *Stom {
+	return s.WithConfig(s.config.WithSliceMode(mode))
+}
+
+// WithIndexBrackets derives a new SToM that puts indices of flattened elements in brackets
+func (s *Stom) WithIndexBrackets(brackets bool) *Stom {
+	return s.WithConfig(s.config.WithIndexBrackets(brackets))
+}
+
+// WithSeparator derives a new SToM with given separator for keys of flattened struct fields
+func (s *Stom) WithSeparator(separator string) *Stom {
+	return s.WithConfig(s.config.WithSeparator(separator))
+}
+
+// WithNaming derives a new SToM that names untagged fields with given strategy
+func (s *Stom) WithNaming(naming NamingStrategy) *Stom {
+	return s.WithConfig(s.config.WithNaming(naming))
+}
+
+// WithStrict derives a new SToM that fails conversions of structures
+// having several fields producing the same key
+func (s *Stom) WithStrict(strict bool) *Stom {
+	return s.WithConfig(s.config.WithStrict(strict))
+}
+
+// WithForceReflect derives a new SToM that ignores ToMap() method of structures
+// implementing ToMappable
+func (s *Stom) WithForceReflect(force bool) *Stom {
+	return s.WithConfig(s.config.WithForceReflect(force))
+}
+
+// WithJSONCompat derives a new SToM that converts structures exactly as encoding/json does
+func (s *Stom) WithJSONCompat(compat bool) *Stom {
+	return s.WithConfig(s.config.WithJSONCompat(compat))
+}
+
+// SetTag sets SToM to scan for given tag in structure.
+// Set... methods modify SToM in place, so they must not be called while SToM
+// is used by other goroutines. Use With... methods to derive a new SToM instead
+func (s *Stom) SetTag(tag string) *Stom {
+	s.config = s.config.WithTag(tag)
+	s.scan()
+
+	return s
+}
+
+// SetTags sets SToM to scan for given chain of tags in structure
+func (s *Stom) SetTags(tags ...string) *Stom {
+	s.config = s.config.WithTags(tags...)
+	s.scan()
 
 	return s
 }
 
+// SetTagParsers sets SToM to recognize fields with given chain of parsers
+func (s *Stom) SetTagParsers(parsers ...TagParser) *Stom {
+	s.config = s.config.WithTagParsers(parsers...)
+	s.scan()
+
+	return s
+}
+
+// SetStructMode sets the way SToM deals with named fields of struct types
+func (s *Stom) SetStructMode(mode StructMode) *Stom {
+	s.config = s.config.WithStructMode(mode)
+	s.scan()
+
+	return s
+}
+
+// SetSliceMode sets the way SToM deals with named fields of slice and array types with struct elements
+func (s *Stom) SetSliceMode(mode SliceMode) *Stom {
+	s.config = s.config.WithSliceMode(mode)
+	s.scan()
+
+	return s
+}
+
+// SetIndexBrackets sets SToM to put indices of flattened elements in brackets
+func (s *Stom) SetIndexBrackets(brackets bool) *Stom {
+	s.config = s.config.WithIndexBrackets(brackets)
+
+	return s
+}
+
+// SetSeparator sets separator for keys of flattened struct fields
+func (s *Stom) SetSeparator(separator string) *Stom {
+	s.config = s.config.WithSeparator(separator)
+	s.scan()
+
+	return s
+}
+
+// SetNaming sets naming strategy for untagged fields
+func (s *Stom) SetNaming(naming NamingStrategy) *Stom {
+	s.config = s.config.WithNaming(naming)
+	s.scan()
+
+	return s
+}
+
+// SetStrict makes SToM to fail conversions of structures that have
+// several fields producing the same key
+func (s *Stom) SetStrict(strict bool) *Stom {
+	s.config = s.config.WithStrict(strict)
+	s.scan()
+
+	return s
+}
+
+// SetForceReflect makes SToM to ignore ToMap() method of structures implementing
+// ToMappable and to convert them using reflection
+func (s *Stom) SetForceReflect(force bool) *Stom {
+	s.config = s.config.WithForceReflect(force)
+
+	return s
+}
+
+// SetJSONCompat switches encoding/json compatibility mode of SToM
+func (s *Stom) SetJSONCompat(compat bool) *Stom {
+	s.config = s.config.WithJSONCompat(compat)
+	s.scan()
+
+	return s
+}
+
+// SetValueConverter sets SToM to convert values of given type with given converter
+func (s *Stom) SetValueConverter(typ reflect.Type, converter ValueConverter) *Stom {
+	s.config = s.config.WithValueConverter(typ, converter)
+	s.scan()
+
+	return s
+}
+
+// SetUnwrapValuer makes SToM to put results of Value() of driver.Valuer values
+// into resulting map instead of the values themselves
+func (s *Stom) SetUnwrapValuer(unwrap bool) *Stom {
+	s.config = s.config.WithUnwrapValuer(unwrap)
+
+	return s
+}
+
+// SetHooks sets SToM to recognize values of fields with given hooks
+func (s *Stom) SetHooks(hooks ...Hook) *Stom {
+	s.config = s.config.WithHooks(hooks...)
+	s.scan()
+
+	return s
+}
+
+// scan analyzes the type with current settings and caches the results
+func (s *Stom) scan() {
+	s.cache = cachedTagValues(s.typ, s.config)
+	s.tagValues = s.cache.TagsList()
+	s.err = checkTags(s.typ, s.cache, s.config)
+}
+
 // SetDefault makes SToM to put given default value in 'nil' values of structure's fields
-func (s *stom) SetDefault(defaultValue interface{}) *stom {
-	s.defaultValue = defaultValue
+func (s *Stom) SetDefault(defaultValue interface{}) *Stom {
+	s.config = s.config.WithDefault(defaultValue)
 
 	return s
 }
 
 // SetPolicy sets policy for 'nil' values
-func (s *stom) SetPolicy(policy Policy) *stom {
-	s.policy = policy
+func (s *Stom) SetPolicy(policy Policy) *Stom {
+	s.config = s.config.WithPolicy(policy)
 
 	return s
 }
 
+// Tag returns tag SToM scans for in structure
+func (s *Stom) Tag() string {
+	return s.config.Tag()
+}
+
+// Tags returns chain of tags SToM scans for in structure
+func (s *Stom) Tags() []string {
+	return s.config.Tags()
+}
+
+// TagParsers returns chain of parsers SToM recognizes fields with
+func (s *Stom) TagParsers() []TagParser {
+	return s.config.TagParsers()
+}
+
+// Policy returns policy for 'nil' values
+func (s *Stom) Policy() Policy {
+	return s.config.policy
+}
+
+// Default returns value SToM puts in 'nil' values of structure's fields
+func (s *Stom) Default() interface{} {
+	return s.config.defaultValue
+}
+
+// Config returns all settings of SToM
+func (s *Stom) Config() Config {
+	return s.config
+}
+
 // TagValues returns list of cached tag values that were processed by SToM
-// Note that these tag values do not include tag values of nested structures
-func (s *stom) TagValues() []string {
+// Tag values of embedded structures are included along with their prefixes,
+// tag values of structures converted to nested maps are not. Keys of flattened
+// elements of slices depend on their length, so the key of the slice field is listed
+func (s *Stom) TagValues() []string {
 	return s.tagValues
 }
 
 // ToMap converts a structure to map[string]interface{}.
-// SToM converts only structures it was initialized for
-func (s *stom) ToMap(obj interface{}) (map[string]interface{}, error) {
+// SToM converts only structures it was initialized for.
+// If the structure implements ToMappable, its ToMap() method is used
+// unless SToM is set up to force reflection
+func (s *Stom) ToMap(obj interface{}) (map[string]interface{}, error) {
 	typ, err := getStructType(obj)
 	if err != nil {
 		return nil, err
@@ -162,50 +479,93 @@ func (s *stom) ToMap(obj interface{}) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("stom is set up to work with type %s, but %s given", s.typ, typ)
 	}
 
-	return toMap(obj, s.cache, s.defaultValue, s.policy)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if m, ok, err := customToMap(obj, s.config); ok {
+		return m, err
+	}
+
+	return toMap(obj, s.cache, s.config)
 }
 
-// SetTag sets package setting for tag to look for in incoming structures
-func SetTag(t string) { tagSetting = t }
+// ConvertToMap converts given structure into map[string]interface{} with package settings
+func ConvertToMap(s interface{}) (map[string]interface{}, error) {
+	return ConvertToMapWith(s, PackageConfig())
+}
 
-// SetDefault sets package default value to set instead of 'nil' in resulting maps
-func SetDefault(dv interface{}) { defaultValueSetting = dv }
+// ConvertToMapWith converts given structure into map[string]interface{} with given settings
+func ConvertToMapWith(s interface{}, config Config) (map[string]interface{}, error) {
+	if m, ok, err := customToMap(s, config); ok {
+		return m, err
+	}
 
-// SetPolicy sets package setting for policy. Policy defines what to do with
-// 'nil' values in resulting maps.
-// There are 2 policies:
-// - PolicyUseDefault - with this policy default value will be used instead of 'nil'
-// - PolicyExclude    - 'nil' values will be discarded
-func SetPolicy(p Policy) { policySetting = p }
+	return DefaultWith(s, config)
+}
 
-// ConvertToMap converts given structure into map[string]interface{}
-func ConvertToMap(s interface{}) (map[string]interface{}, error) {
-	if tomappable, ok := s.(ToMappable); ok {
-		return tomappable.ToMap()
+// customToMap converts a structure with its own ToMapWith() or ToMap() method,
+// unless settings force reflection. Returns false if the structure is converted by SToM
+func customToMap(s interface{}, config Config) (map[string]interface{}, bool, error) {
+	if !config.useToMappable() {
+		return nil, false, nil
 	}
 
+	switch t := s.(type) {
+	case ToMappableWith:
+		m, err := t.ToMapWith(config)
+		return m, true, err
+	case ToMappable:
+		m, err := t.ToMap()
+		return m, true, err
+	}
+
+	return nil, false, nil
+}
+
+// Default converts given structure into map[string]interface{} using reflection
+// even if the structure implements ToMappable. It allows custom ToMap() method
+// to extend the default conversion rather than replace it. Default always uses
+// package settings, even if ToMap() is called by a SToM with its own settings,
+// implement ToMappableWith and call DefaultWith to follow settings of the caller:
+//
+//	func (i Item) ToMap() (map[string]interface{}, error) {
+//		m, err := stom.Default(i)
+//		if err == nil {
+//			m["computed"] = i.Compute()
+//		}
+//		return m, err
+//	}
+func Default(s interface{}) (map[string]interface{}, error) {
+	return DefaultWith(s, PackageConfig())
+}
+
+// DefaultWith does the same as Default, but with given settings
+func DefaultWith(s interface{}, config Config) (map[string]interface{}, error) {
 	typ, err := getStructType(s)
 	if err != nil {
 		return nil, err
 	}
 
-	tagmap := extractTagValues(typ, tagSetting)
+	tagmap := cachedTagValues(typ, config)
+	if err := checkTags(typ, tagmap, config); err != nil {
+		return nil, err
+	}
 
-	return toMap(s, tagmap, defaultValueSetting, policySetting)
+	return toMap(s, tagmap, config)
 }
 
 func getStructType(s interface{}) (t reflect.Type, err error) {
 	t = reflect.TypeOf(s)
+	if t == nil {
+		err = fmt.Errorf("value is invalid:\n %v", s)
+		return
+	}
 
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
 
-	if t.Kind() == reflect.Invalid {
-		err = fmt.Errorf("value is invalid:\n %v", s)
-		return
-	}
-
 	if t.Kind() != reflect.Struct {
 		err = fmt.Errorf("provided value is not a struct but %v", t.Kind())
 	}
@@ -213,98 +573,248 @@ func getStructType(s interface{}) (t reflect.Type, err error) {
 	return
 }
 
-// extractTagValues scans given type and tries to find all fields with given tag
-// Indices of all found fields are stored as values in resulting map
-// Keys of resulting map are actual values of tags
-func extractTagValues(typ reflect.Type, tag string) tags {
-	if typ.Kind() == reflect.Ptr {
-		typ = typ.Elem()
+// checkTags returns *FieldError if tag options of some field are invalid
+// and *DuplicateKeyError if there are duplicate keys in strict mode
+func checkTags(typ reflect.Type, tagmap tags, config Config) error {
+	if len(tagmap.Errors) > 0 {
+		return tagmap.Errors[0]
 	}
-	tagValues := newTags()
-
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		tagValue := field.Tag.Get(tag)
-
-		if field.Anonymous && tagValue != "-" {
-			tagValues.Nested[i] = extractTagValues(field.Type, tag)
-			continue
-		}
-
-		if tagValue != "" && tagValue != "-" && field.PkgPath == "" { // exported
-			tagValues.Simple[i] = tagValue
-		}
 
+	if !config.strict || len(tagmap.Conflicts) == 0 {
+		return nil
 	}
 
-	return tagValues
+	return &DuplicateKeyError{Type: typ, Conflicts: tagmap.Conflicts}
 }
 
-func toMap(obj interface{}, tagmap tags, defaultValue interface{}, policy Policy) (map[string]interface{}, error) {
+func toMap(obj interface{}, tagmap tags, config Config) (map[string]interface{}, error) {
+	if config.jsonCompat {
+		return jsonToMap(obj, config)
+	}
+
 	val := reflect.ValueOf(obj)
 
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
 	}
 
-	result := map[string]interface{}{}
+	// fields of a structure passed by value are copied to be addressable,
+	// so hooks see the same methods as for a pointer to the structure
+	if !val.CanAddr() {
+		addressable := reflect.New(val.Type()).Elem()
+		addressable.Set(val)
+		val = addressable
+	}
 
-	for index, tag := range tagmap.Simple {
-		vField := val.Field(index)
+	result := make(map[string]interface{}, len(tagmap.Fields))
 
-		v, err := filterValue(vField)
+	for _, f := range tagmap.Fields {
+		vField, ok := fieldByIndex(val, f.index)
+
+		var v interface{}
+		var err error
+		var converted bool
+		if ok {
+			v, converted, err = convertValue(vField, config)
+		}
+
+		switch {
+		case !ok:
+			// fields of embedded structures referenced by nil pointers are 'nil'
+		case converted || err != nil:
+			// registered converters go before the built-in handling
+		case f.indexed:
+			if err = flattenElements(result, f.name, vField, *f.nested, config); err == nil {
+				continue
+			}
+		case f.nested != nil:
+			v, err = nestedValue(vField, *f.nested, config)
+		default:
+			v, err = filterValue(vField, config)
+		}
 
 		if err != nil {
-			return result, err
+			return result, newFieldError(f, err)
+		}
+
+		if f.opts.omitEmpty && (v == nil || isEmptyValue(vField)) {
+			continue
+		}
+
+		policy := f.policy(config)
+		if policy == PolicyOmitZero && (v == nil || isZeroValue(vField)) {
+			continue
 		}
 
 		if v != nil {
-			result[tag] = v
-		} else if policy == PolicyUseDefault {
-			result[tag] = defaultValue
+			if f.opts.asString {
+				v = stringify(v)
+			}
+			result[f.name] = v
+			continue
+		}
+
+		switch policy {
+		case PolicyUseDefault:
+			result[f.name] = f.defaultValue(config)
+		case PolicyKeepNil:
+			result[f.name] = nil
+		case PolicyError:
+			return result, newFieldError(f, ErrNilValue)
+		}
+	}
+
+	return result, nil
+}
+
+// fieldByIndex returns a field by its index sequence.
+// Returns false if the field belongs to embedded structure referenced by nil pointer
+func fieldByIndex(val reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return val, false
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+
+	return val, true
+}
+
+// nestedValue converts a struct field to nested map[string]interface{}
+// and a slice field to []map[string]interface{}
+func nestedValue(vField reflect.Value, tagmap tags, config Config) (interface{}, error) {
+	switch vField.Kind() {
+	case reflect.Ptr:
+		if vField.IsNil() {
+			return nil, nil
+		}
+	case reflect.Slice, reflect.Array:
+		return nestedElements(vField, tagmap, config)
+	}
+
+	return toMap(vField.Interface(), tagmap, config)
+}
+
+// nestedElements converts elements of a slice field to nested maps.
+// Nil slice is 'nil', nil pointers to elements become nil maps
+func nestedElements(vField reflect.Value, tagmap tags, config Config) (interface{}, error) {
+	if vField.Kind() == reflect.Slice && vField.IsNil() {
+		return nil, nil
+	}
+
+	elements := make([]map[string]interface{}, vField.Len())
+	for i := range elements {
+		elem := vField.Index(i)
+		if elem.Kind() == reflect.Ptr && elem.IsNil() {
+			continue
 		}
 
+		m, err := toMap(elem.Interface(), tagmap, config)
+		if err != nil {
+			return nil, newElementError(i, err)
+		}
+		elements[i] = m
 	}
 
-	for index, tags := range tagmap.Nested {
-		vField := val.Field(index)
-		valueMap, err := toMap(vField.Interface(), tags, defaultValue, policy)
+	return elements, nil
+}
+
+// flattenElements puts fields of elements of a slice field into resulting map
+// with keys prefixed by key of the field and index of an element.
+// Nil pointers to elements produce no keys
+func flattenElements(result map[string]interface{}, name string, vField reflect.Value, tagmap tags, config Config) error {
+	for i := 0; i < vField.Len(); i++ {
+		elem := vField.Index(i)
+		if elem.Kind() == reflect.Ptr && elem.IsNil() {
+			continue
+		}
+
+		m, err := toMap(elem.Interface(), tagmap, config)
 		if err != nil {
-			return result, err
+			return newElementError(i, err)
+		}
+
+		prefix := name + config.separator + strconv.Itoa(i) + config.separator
+		if config.indexBrackets {
+			prefix = name + "[" + strconv.Itoa(i) + "]" + config.separator
 		}
-		for k, v := range valueMap {
-			result[k] = v
+		for key, v := range m {
+			result[prefix+key] = v
 		}
 	}
 
-	return result, nil
+	return nil
 }
 
 // filterValue filters given value of some structure's field.
-// Simple values are left as is. There is some special logic about particular types
-// like ToMappable
-func filterValue(vField reflect.Value) (v interface{}, err error) {
+// Simple values are left as is, values implementing particular interfaces
+// like ToMappable are converted with hooks
+func filterValue(vField reflect.Value, config Config) (v interface{}, err error) {
+	var ref interface{}
+
 	kind := vField.Kind()
 	if kind == reflect.Ptr {
 		if vField.Elem().IsValid() {
-			v = vField.Elem().Interface()
+			v, ref = vField.Elem().Interface(), vField.Interface()
 		}
 	} else {
 		v = vField.Interface()
+		if vField.CanAddr() {
+			ref = vField.Addr().Interface()
+		}
 	}
+
+	if v == nil {
+		return nil, nil
+	}
+
+	return applyHooks(v, ref, config)
+}
+
+// isEmptyValue checks whether given value is a zero value in terms of 'omitempty' option
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// isZeroValue checks whether given value is a zero value in terms of PolicyOmitZero
+func isZeroValue(v reflect.Value) bool {
+	return isEmptyValue(v) || v.IsZero()
+}
+
+// stringify converts a filtered value to string for fields with 'string' option
+func stringify(v interface{}) string {
 	switch t := v.(type) {
-	case driver.Valuer: // support for NullTypes like sql.NullString and so on
-		if converted, convErr := t.Value(); convErr != nil || converted == nil {
-			v = nil
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case driver.Valuer:
+		if converted, err := t.Value(); err == nil && converted != nil {
+			return stringify(converted)
 		}
-	case Zeroable:
-		if t.IsZero() {
-			v = nil
-		}
-	case ToMappable:
-		v, err = t.ToMap()
+	case fmt.Stringer:
+		return t.String()
 	}
 
-	return v, nil
-
+	return fmt.Sprint(v)
 }
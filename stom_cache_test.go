@@ -0,0 +1,80 @@
+package stom_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_Warm(t *testing.T) {
+	config := stom.NewConfig()
+
+	assert.NoError(t, stom.WarmCache(config, SomeItem{}, &ComplexItem{}, DuplicatesItem{}))
+	assert.Error(t, stom.WarmCache(config, SomeItem{}, 123))
+	assert.Error(t, stom.WarmCache(config.WithStrict(true), DuplicatesItem{}))
+}
+
+func TestCache_ConcurrentUse(t *testing.T) {
+	configs := []stom.Config{
+		stom.NewConfig(),
+		stom.NewConfig().WithTag("custom_tag"),
+		stom.NewConfig().WithStructMode(stom.StructFlatten),
+	}
+
+	expecteds := make([]map[string]interface{}, len(configs))
+	for i, config := range configs {
+		m, err := stom.ConvertToMapWith(getTestCustomer(), config)
+		assert.NoError(t, err)
+		expecteds[i] = m
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for j := range configs {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				m, err := stom.ConvertToMapWith(getTestCustomer(), configs[j])
+				assert.NoError(t, err)
+				assert.Equal(t, expecteds[j], m)
+			}(j)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stom.ResetCache()
+		}()
+	}
+	wg.Wait()
+}
+
+type RegisteredItem struct {
+	Name string `db:"name"`
+}
+
+func TestCache_RegisterDuringConversion(t *testing.T) {
+	defer stom.Unregister(RegisteredItem{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := stom.ConvertToMapWith(RegisteredItem{Name: "foo"}, stom.NewConfig())
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			stom.Unregister(RegisteredItem{})
+			assert.NoError(t, stom.Register(RegisteredItem{}, stom.NewMapping().Field("Name", "title")))
+		}()
+	}
+	wg.Wait()
+
+	m, err := stom.ConvertToMapWith(RegisteredItem{Name: "foo"}, stom.NewConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"title": "foo"}, m)
+}
@@ -194,3 +194,80 @@ func getTestComplexItem() ComplexItem {
 
 	return item
 }
+
+func TestComplexItem_NilEmbeddedPointers(t *testing.T) {
+	posted := mysql.NullTime{Time: time.Unix(12000, 0), Valid: true}
+
+	cases := []struct {
+		name      string
+		basicItem *BasicItem
+		policy    stom.Policy
+		expected  map[string]interface{}
+	}{
+		{
+			name:      "nil BasicItem, default policy",
+			basicItem: nil,
+			policy:    stom.PolicyUseDefault,
+			expected:  map[string]interface{}{"base": "DEFAULT", "basic_posted": "DEFAULT"},
+		},
+		{
+			name:      "nil BasicItem, exclude policy",
+			basicItem: nil,
+			policy:    stom.PolicyExclude,
+			expected:  map[string]interface{}{},
+		},
+		{
+			name:      "nil ParentItem, default policy",
+			basicItem: &BasicItem{Posted: posted},
+			policy:    stom.PolicyUseDefault,
+			expected:  map[string]interface{}{"base": "DEFAULT", "basic_posted": posted},
+		},
+		{
+			name:      "nil ParentItem, exclude policy",
+			basicItem: &BasicItem{Posted: posted},
+			policy:    stom.PolicyExclude,
+			expected:  map[string]interface{}{"basic_posted": posted},
+		},
+		{
+			name:      "no nil pointers, default policy",
+			basicItem: &BasicItem{ParentItem: &ParentItem{Base: "base"}, Posted: posted},
+			policy:    stom.PolicyUseDefault,
+			expected:  map[string]interface{}{"base": "base", "basic_posted": posted},
+		},
+		{
+			name:      "no nil pointers, exclude policy",
+			basicItem: &BasicItem{ParentItem: &ParentItem{Base: "base"}, Posted: posted},
+			policy:    stom.PolicyExclude,
+			expected:  map[string]interface{}{"base": "base", "basic_posted": posted},
+		},
+	}
+
+	defer restorePackageSettings(stom.PackageConfig())
+	stom.SetTag("db")
+	stom.SetDefault("DEFAULT")
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stom.SetPolicy(c.policy)
+
+			item := ComplexItem{BasicItem: c.basicItem}
+			converter := stom.MustNewStom(item).SetTag("db").SetPolicy(c.policy).SetDefault("DEFAULT")
+
+			for _, tomapper := range []stom.ToMapper{stom.ToMapperFunc(stom.ConvertToMap), converter} {
+				actual, err := tomapper.ToMap(item)
+				if err != nil {
+					t.Fatalf("ToMap call returned error: %s", err.Error())
+				}
+
+				for _, key := range []string{"base", "basic_posted"} {
+					expected, expectedOk := c.expected[key]
+					value, ok := actual[key]
+					if expectedOk != ok || expected != value {
+						t.Fatalf("expected value by key %s is %#v (present: %t), got %#v (present: %t)",
+							key, expected, expectedOk, value, ok)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package stom_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Immutable(t *testing.T) {
+	base := stom.NewConfig()
+	custom := base.WithTag("custom_tag").WithPolicy(stom.PolicyExclude).WithDefault("foo")
+
+	assert.Equal(t, "db", base.Tag())
+	assert.Equal(t, stom.PolicyUseDefault, base.Policy())
+	assert.Nil(t, base.Default())
+
+	assert.Equal(t, "custom_tag", custom.Tag())
+	assert.Equal(t, stom.PolicyExclude, custom.Policy())
+	assert.Equal(t, "foo", custom.Default())
+}
+
+func TestConfig_IgnoresPackageSettings(t *testing.T) {
+	stom.SetTag("db")
+	stom.SetPolicy(stom.PolicyUseDefault)
+	stom.SetDefault("DEFAULT")
+
+	config := stom.NewConfig().WithTag("custom_tag").WithPolicy(stom.PolicyExclude)
+	item := SomeItem{ID: 1, Number: 2, SomeIgnoreField: 3}
+	expected := map[string]interface{}{
+		"id":               1,
+		"num":              2,
+		"sum":              int32(0),
+		"visible":          false,
+		"i_ignore_nothing": 3,
+	}
+
+	tomappers := []stom.ToMapper{
+		stom.ToMapperFunc(func(s interface{}) (map[string]interface{}, error) {
+			return stom.ConvertToMapWith(s, config)
+		}),
+		stom.MustNewStomWith(SomeItem{}, config),
+	}
+
+	for _, tomapper := range tomappers {
+		doTest(t, tomapper, item, expected)
+	}
+
+	var actual SomeItem
+	assert.NoError(t, stom.ConvertFromMapWith(expected, &actual, config))
+	assert.Equal(t, item, actual)
+}
+
+// restorePackageSettings brings back package settings saved with stom.PackageConfig,
+// so tests changing them don't affect each other
+func restorePackageSettings(config stom.Config) {
+	if tags := config.Tags(); tags != nil {
+		stom.SetTags(tags...)
+	} else {
+		stom.SetTagParsers(config.TagParsers()...)
+	}
+	stom.SetPolicy(config.Policy())
+	stom.SetDefault(config.Default())
+	stom.SetStructMode(config.StructMode())
+	stom.SetSliceMode(config.SliceMode())
+	stom.SetIndexBrackets(config.IndexBrackets())
+	stom.SetSeparator(config.Separator())
+	stom.SetNaming(config.Naming())
+	stom.SetStrict(config.Strict())
+	stom.SetForceReflect(config.ForceReflect())
+	stom.SetJSONCompat(config.JSONCompat())
+	stom.SetUnwrapValuer(config.UnwrapValuer())
+	stom.SetHooks(config.Hooks()...)
+}
+
+func TestConfig_PackageSettingsConcurrency(t *testing.T) {
+	defer restorePackageSettings(stom.PackageConfig())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			stom.SetTag("custom_tag")
+			stom.SetPolicy(stom.PolicyExclude)
+			stom.SetDefault(nil)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := stom.ConvertToMap(SomeItem{})
+			assert.NoError(t, err)
+			assert.NotPanics(t, func() { stom.MustNewStom(SomeItem{}) })
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, "custom_tag", stom.PackageConfig().Tag())
+}
+
+func TestConfig_StomGetters(t *testing.T) {
+	var converter *stom.Stom
+	converter, err := stom.NewStomWith(SomeItem{}, stom.NewConfig().WithTag("custom_tag"))
+	if err != nil {
+		t.Fatalf("NewStomWith call returned error: %s", err.Error())
+	}
+
+	converter.SetPolicy(stom.PolicyExclude).SetDefault("foo")
+
+	assert.Equal(t, "custom_tag", converter.Tag())
+	assert.Equal(t, stom.PolicyExclude, converter.Policy())
+	assert.Equal(t, "foo", converter.Default())
+	assert.Equal(t, stom.NewConfig().WithTag("custom_tag").WithPolicy(stom.PolicyExclude).WithDefault("foo"), converter.Config())
+
+	var tomapper stom.ToMapper = converter
+	var frommapper stom.FromMapper = converter
+	assert.NotNil(t, tomapper)
+	assert.NotNil(t, frommapper)
+}
+
+func TestConfig_NewStomErrors(t *testing.T) {
+	_, err := stom.NewStom(123)
+	assert.Error(t, err)
+
+	_, err = stom.NewStomWith(DuplicatesItem{}, stom.NewConfig().WithStrict(true))
+	assert.Error(t, err)
+
+	_, err = stom.NewStom(nil)
+	assert.EqualError(t, err, "value is invalid:\n <nil>")
+	assert.Error(t, stom.WarmCache(stom.NewConfig(), nil))
+	assert.Error(t, stom.Register(nil, stom.NewMapping()))
+	_, err = stom.ConvertToMap(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package stom_test
+
+import (
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type BookService struct {
+	rows *stom.Converter[BookRow]
+}
+
+func TestConverter_ToMap(t *testing.T) {
+	converter, err := stom.ForConfig[BookRow](stom.NewConfig().WithSeparator("_"))
+	if err != nil {
+		t.Fatalf("ForConfig call returned error: %s", err.Error())
+	}
+	service := BookService{rows: converter}
+
+	row := BookRow{Book: Book{ID: 1, Title: "title"}, Author: &Author{ID: 2}}
+	expected := map[string]interface{}{
+		"id":           1,
+		"title":        "title",
+		"author_id":    2,
+		"author_name":  "",
+		"pub_id":       0,
+		"pub_name":     "",
+		"address_city": "",
+	}
+
+	m, err := service.rows.ToMap(row)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, m)
+
+	m, err = service.rows.ToMapPtr(&row)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, m)
+
+	_, err = service.rows.ToMapPtr(nil)
+	assert.Error(t, err)
+
+	var actual BookRow
+	assert.NoError(t, service.rows.FromMap(map[string]interface{}{"id": 1, "author_id": 2}, &actual))
+	assert.Equal(t, BookRow{Book: Book{ID: 1}, Author: &Author{ID: 2}}, actual)
+
+	assert.Equal(t, "_", service.rows.Config().Separator())
+}
+
+func TestConverter_Errors(t *testing.T) {
+	_, err := stom.For[int]()
+	assert.Error(t, err)
+
+	_, err = stom.For[*Order]()
+	assert.Error(t, err)
+
+	_, err = stom.ForConfig[DuplicatesItem](stom.NewConfig().WithStrict(true))
+	assert.Error(t, err)
+
+	converter, err := stom.ForConfig[DuplicatesItem](stom.NewConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"created", "author", "id"}, converter.TagValues())
+}
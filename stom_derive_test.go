@@ -0,0 +1,66 @@
+package stom_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type APIItem struct {
+	ID       int     `db:"id" json:"id"`
+	Secret   string  `db:"secret" json:"-"`
+	Discount *string `db:"discount" json:"discount"`
+}
+
+func TestDerive_DoesNotModifyBase(t *testing.T) {
+	base := stom.MustNewStomWith(APIItem{}, stom.NewConfig())
+
+	storage := base.WithPolicy(stom.PolicyUseDefault).WithDefault("DEFAULT")
+	api := base.WithTag("json").WithPolicy(stom.PolicyExclude)
+
+	assert.Equal(t, "db", base.Tag())
+	assert.Equal(t, stom.PolicyUseDefault, base.Policy())
+	assert.Nil(t, base.Default())
+	assert.Equal(t, []string{"id", "secret", "discount"}, base.TagValues())
+	assert.Equal(t, []string{"id", "discount"}, api.TagValues())
+
+	item := APIItem{ID: 1, Secret: "secret"}
+	doTest(t, base, item, map[string]interface{}{"id": 1, "secret": "secret", "discount": nil})
+	doTest(t, storage, item, map[string]interface{}{"id": 1, "secret": "secret", "discount": "DEFAULT"})
+	doTest(t, api, item, map[string]interface{}{"id": 1})
+}
+
+func TestDerive_Strict(t *testing.T) {
+	base := stom.MustNewStomWith(DuplicatesItem{}, stom.NewConfig())
+
+	_, err := base.WithStrict(true).ToMap(DuplicatesItem{})
+	assert.Error(t, err)
+
+	_, err = base.ToMap(DuplicatesItem{})
+	assert.NoError(t, err)
+}
+
+func TestDerive_Concurrency(t *testing.T) {
+	base := stom.MustNewStomWith(APIItem{}, stom.NewConfig())
+	item := APIItem{ID: 1, Secret: "secret"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m, err := base.WithTag("json").WithPolicy(stom.PolicyExclude).ToMap(item)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"id": 1}, m)
+		}()
+		go func() {
+			defer wg.Done()
+			m, err := base.ToMap(item)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"id": 1, "secret": "secret", "discount": nil}, m)
+		}()
+	}
+	wg.Wait()
+}
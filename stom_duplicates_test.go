@@ -0,0 +1,89 @@
+package stom_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type Timestamps struct {
+	ID      int `db:"id"`
+	Created int `db:"created"`
+	Updated int `db:"updated"`
+}
+
+type Audit struct {
+	Updated int `db:"updated"`
+	Author  int `db:"author"`
+}
+
+type DuplicatesItem struct {
+	Timestamps
+	Audit
+	ID int `db:"id"`
+}
+
+func TestDuplicates_Dominance(t *testing.T) {
+	stom.SetTag("db")
+	stom.SetStrict(false)
+
+	item := DuplicatesItem{
+		Timestamps: Timestamps{ID: 1, Created: 2, Updated: 3},
+		Audit:      Audit{Updated: 4, Author: 5},
+		ID:         6,
+	}
+
+	// shallowest "id" wins, "updated" is ambiguous and dropped
+	expected := map[string]interface{}{
+		"id":      6,
+		"created": 2,
+		"author":  5,
+	}
+
+	for i := 0; i < 10; i++ {
+		doTest(t, stom.ToMapperFunc(stom.ConvertToMap), item, expected)
+	}
+
+	converter := stom.MustNewStom(DuplicatesItem{})
+	assert.Equal(t, []string{"created", "author", "id"}, converter.TagValues())
+	doTest(t, converter, item, expected)
+}
+
+func TestDuplicates_Strict(t *testing.T) {
+	stom.SetTag("db")
+	stom.SetStrict(true)
+	defer stom.SetStrict(false)
+
+	_, err := stom.ConvertToMap(DuplicatesItem{})
+
+	var duplicateErr *stom.DuplicateKeyError
+	if !errors.As(err, &duplicateErr) {
+		t.Fatalf("expected *DuplicateKeyError, got %v", err)
+	}
+	assert.Equal(t, []stom.KeyConflict{
+		{Key: "id", Paths: []string{"ID", "Timestamps.ID"}},
+		{Key: "updated", Paths: []string{"Timestamps.Updated", "Audit.Updated"}},
+	}, duplicateErr.Conflicts)
+	assert.EqualError(t, err, "duplicate keys in type stom_test.DuplicatesItem: "+
+		"id (ID, Timestamps.ID); updated (Timestamps.Updated, Audit.Updated)")
+
+	assert.Error(t, stom.ConvertFromMap(map[string]interface{}{}, &DuplicatesItem{}))
+
+	assert.Panics(t, func() { stom.MustNewStom(DuplicatesItem{}) })
+
+	// structures without conflicts are converted as usual
+	_, err = stom.ConvertToMap(Timestamps{})
+	assert.NoError(t, err)
+}
+
+func TestDuplicates_StrictConverter(t *testing.T) {
+	converter := stom.MustNewStom(DuplicatesItem{}).SetTag("db").SetStrict(true)
+
+	_, err := converter.ToMap(DuplicatesItem{})
+	assert.Error(t, err)
+
+	_, err = converter.SetStrict(false).ToMap(DuplicatesItem{})
+	assert.NoError(t, err)
+}
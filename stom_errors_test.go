@@ -0,0 +1,82 @@
+package stom_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+var errBrokenMeta = errors.New("broken meta")
+
+type BrokenMeta struct{}
+
+// ToMap implements ToMappable interface and always fails
+func (BrokenMeta) ToMap() (map[string]interface{}, error) {
+	return nil, errBrokenMeta
+}
+
+type BrokenParent struct {
+	Meta BrokenMeta `db:"meta"`
+}
+
+type BrokenBasic struct {
+	*BrokenParent
+}
+
+type BrokenItem struct {
+	ID int `db:"id"`
+	BrokenBasic
+}
+
+type BrokenOwner struct {
+	Item BrokenItem `db:"item,nest"`
+}
+
+func TestErrors_ToMappableField(t *testing.T) {
+	stom.SetTag("db")
+	item := BrokenItem{ID: 1, BrokenBasic: BrokenBasic{BrokenParent: &BrokenParent{}}}
+
+	tomappers := []stom.ToMapper{
+		stom.ToMapperFunc(stom.ConvertToMap),
+		stom.MustNewStom(item).SetTag("db"),
+	}
+
+	for _, tomapper := range tomappers {
+		_, err := tomapper.ToMap(item)
+
+		assert.True(t, errors.Is(err, errBrokenMeta))
+
+		var fieldErr *stom.FieldError
+		if assert.True(t, errors.As(err, &fieldErr)) {
+			assert.Equal(t, "BrokenBasic.BrokenParent.Meta", fieldErr.Path)
+			assert.Equal(t, "meta", fieldErr.Key)
+		}
+		assert.EqualError(t, err, "field BrokenBasic.BrokenParent.Meta (key meta): broken meta")
+	}
+}
+
+func TestErrors_NestedField(t *testing.T) {
+	owner := BrokenOwner{Item: BrokenItem{BrokenBasic: BrokenBasic{BrokenParent: &BrokenParent{}}}}
+
+	_, err := stom.MustNewStom(owner).SetTag("db").ToMap(owner)
+
+	var fieldErr *stom.FieldError
+	if assert.True(t, errors.As(err, &fieldErr)) {
+		assert.Equal(t, "Item.BrokenBasic.BrokenParent.Meta", fieldErr.Path)
+		assert.Equal(t, "item.meta", fieldErr.Key)
+		assert.Equal(t, errBrokenMeta, fieldErr.Err)
+	}
+}
+
+func TestErrors_FromMap(t *testing.T) {
+	err := stom.MustNewStom(ComplexItem{}).SetTag("db").
+		FromMap(map[string]interface{}{"base": 123}, &ComplexItem{})
+
+	var fieldErr *stom.FieldError
+	if assert.True(t, errors.As(err, &fieldErr)) {
+		assert.Equal(t, "BasicItem.ParentItem.Base", fieldErr.Path)
+		assert.Equal(t, "base", fieldErr.Key)
+	}
+}
@@ -0,0 +1,55 @@
+package stom_test
+
+import (
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type FallbackItem struct {
+	ID       int    `db:"item_id" json:"id"`
+	Name     string `json:"name"`
+	Password string `db:"-" json:"password"`
+	Token    string `db:"token" json:"-"`
+	Notes    string
+	hidden   string
+}
+
+func TestFallback_TagChain(t *testing.T) {
+	converter := stom.MustNewStomWith(FallbackItem{}, stom.NewConfig().WithTags("db", "json"))
+
+	assert.Equal(t, "db", converter.Tag())
+	assert.Equal(t, []string{"db", "json"}, converter.Tags())
+	assert.Equal(t, []string{"item_id", "name", "token"}, converter.TagValues())
+
+	doTest(t, converter, FallbackItem{ID: 1, Name: "name", Password: "pwd", Token: "token", Notes: "notes"},
+		map[string]interface{}{
+			"item_id": 1,
+			"name":    "name",
+			"token":   "token",
+		})
+}
+
+func TestFallback_FieldName(t *testing.T) {
+	stom.SetTags("json", "db", stom.FieldName)
+	defer stom.SetTag("db")
+
+	doTest(t, stom.ToMapperFunc(stom.ConvertToMap),
+		FallbackItem{ID: 1, Name: "name", Password: "pwd", Token: "token", Notes: "notes", hidden: "hidden"},
+		map[string]interface{}{
+			"id":       1,
+			"name":     "name",
+			"password": "pwd",
+			"Notes":    "notes",
+		})
+}
+
+func TestFallback_Derive(t *testing.T) {
+	converter := stom.MustNewStomWith(FallbackItem{}, stom.NewConfig()).WithTags("db", stom.FieldName)
+
+	assert.Equal(t, []string{"item_id", "Name", "token", "Notes"}, converter.TagValues())
+
+	converter.SetTags("json")
+	assert.Equal(t, []string{"id", "name", "password"}, converter.TagValues())
+}
@@ -0,0 +1,175 @@
+package stom_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/elgris/stom"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+// FromMap implements FromMappable interface to be used by SToM
+func (this *Metainfo) FromMap(m map[string]interface{}) error {
+	this.Tag, _ = m["tag"].(string)
+	this.Value, _ = m["value"].(string)
+	this.Additional, _ = m["add"].(map[string]interface{})
+
+	return nil
+}
+
+func TestFromMap_RoundTrip(t *testing.T) {
+	stom.SetTag("db")
+	stom.SetDefault("DEFAULT")
+	stom.SetPolicy(stom.PolicyUseDefault)
+
+	for _, item := range getTestItems() {
+		m, err := stom.ConvertToMap(item)
+		if err != nil {
+			t.Fatalf("ToMap call returned error: %s", err.Error())
+		}
+
+		var actual SomeItem
+		if err := stom.ConvertFromMap(m, &actual); err != nil {
+			t.Fatalf("FromMap call returned error: %s", err.Error())
+		}
+
+		// fields without tags and payload of invalid NullTypes cannot survive the round trip
+		item.Checksum = 0
+		item.Notes = ""
+		item.SomeIgnoreField = 0
+		if !item.Updated.Valid {
+			item.Updated = mysql.NullTime{}
+		}
+		if !item.IsReserved.Valid {
+			item.IsReserved = sql.NullBool{}
+		}
+		if !item.Rating.Valid {
+			item.Rating = sql.NullFloat64{}
+		}
+
+		assert.Equal(t, item, actual)
+	}
+}
+
+func TestFromMap_ComplexItem(t *testing.T) {
+	converter := stom.MustNewStom(ComplexItem{}).
+		SetTag("db").
+		SetPolicy(stom.PolicyExclude)
+
+	m := map[string]interface{}{
+		"id":           int64(1),
+		"name":         "item_1",
+		"discount":     111.0,
+		"updated":      time.Unix(11000, 0),
+		"points":       int64(11),
+		"base":         "base",
+		"basic_posted": time.Unix(12000, 0),
+		"author":       "author",
+		"meta": map[string]interface{}{
+			"tag":   "metatag",
+			"value": "valvalval",
+		},
+	}
+
+	var actual ComplexItem
+	if err := converter.FromMap(m, &actual); err != nil {
+		t.Fatalf("FromMap call returned error: %s", err.Error())
+	}
+
+	discount := 111.0
+	assert.Equal(t, 1, actual.ID)
+	assert.Equal(t, "item_1", actual.Name)
+	assert.Equal(t, &discount, actual.Discount)
+	assert.Equal(t, mysql.NullTime{Time: time.Unix(11000, 0), Valid: true}, actual.Updated)
+	assert.Equal(t, sql.NullInt64{Int64: 11, Valid: true}, actual.Points)
+	assert.Equal(t, sql.NullString{String: "author", Valid: true}, actual.Author)
+	assert.Equal(t, Metainfo{Tag: "metatag", Value: "valvalval"}, actual.Meta)
+
+	// nil embedded pointers are allocated on demand
+	if assert.NotNil(t, actual.BasicItem) && assert.NotNil(t, actual.ParentItem) {
+		assert.Equal(t, "base", actual.Base)
+		assert.Equal(t, mysql.NullTime{Time: time.Unix(12000, 0), Valid: true}, actual.Posted)
+	}
+}
+
+func TestFromMap_KeepsNilEmbeddedPointers(t *testing.T) {
+	var actual ComplexItem
+	err := stom.MustNewStom(ComplexItem{}).FromMap(map[string]interface{}{"id": 1}, &actual)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, actual.ID)
+	assert.Nil(t, actual.BasicItem)
+}
+
+func TestFromMap_DefaultValueMeansNil(t *testing.T) {
+	converter := stom.MustNewStom(SomeItem{}).
+		SetTag("db").
+		SetPolicy(stom.PolicyUseDefault).
+		SetDefault("DEFAULT")
+
+	discount := 1.0
+	actual := SomeItem{
+		Discount: &discount,
+		Points:   sql.NullInt64{Int64: 1, Valid: true},
+	}
+	m := map[string]interface{}{
+		"name":     "DEFAULT",
+		"discount": "DEFAULT",
+		"points":   "DEFAULT",
+	}
+
+	if err := converter.FromMap(m, &actual); err != nil {
+		t.Fatalf("FromMap call returned error: %s", err.Error())
+	}
+
+	assert.Equal(t, "DEFAULT", actual.Name)
+	assert.Nil(t, actual.Discount)
+	assert.Equal(t, sql.NullInt64{}, actual.Points)
+}
+
+func TestFromMap_Errors(t *testing.T) {
+	converter := stom.MustNewStomWith(SomeItem{}, stom.NewConfig())
+
+	assert.Error(t, converter.FromMap(map[string]interface{}{}, SomeItem{}))
+	assert.Error(t, converter.FromMap(map[string]interface{}{}, &ComplexItem{}))
+	assert.Error(t, converter.FromMap(map[string]interface{}{"name": 123}, &SomeItem{}))
+	assert.Error(t, stom.ConvertFromMap(map[string]interface{}{}, (*SomeItem)(nil)))
+}
+
+func TestFromMap_Numbers(t *testing.T) {
+	type numbers struct {
+		Small int8    `db:"small"`
+		Whole int     `db:"whole"`
+		Count uint16  `db:"count"`
+		Ratio float32 `db:"ratio"`
+	}
+	config := stom.NewConfig()
+
+	// numbers decoded from JSON are float64
+	var actual numbers
+	assert.NoError(t, stom.ConvertFromMapWith(map[string]interface{}{
+		"small": 100.0,
+		"whole": int64(-5),
+		"count": 7.0,
+		"ratio": 0.5,
+	}, &actual, config))
+	assert.Equal(t, numbers{Small: 100, Whole: -5, Count: 7, Ratio: 0.5}, actual)
+
+	invalid := map[string]interface{}{
+		"small": 300,
+		"whole": 1.9,
+		"count": -1,
+		"ratio": 1e300,
+	}
+	for key, v := range invalid {
+		err := stom.ConvertFromMapWith(map[string]interface{}{key: v}, &actual, config)
+		assert.Error(t, err, "key %s", key)
+	}
+
+	err := stom.ConvertFromMapWith(map[string]interface{}{"small": 300}, &actual, config)
+	assert.EqualError(t, err, "field Small (key small): value 300 overflows int8")
+	err = stom.ConvertFromMapWith(map[string]interface{}{"whole": 1.9}, &actual, config)
+	assert.EqualError(t, err, "field Whole (key whole): value 1.9 is not an integer")
+}
@@ -0,0 +1,191 @@
+package stom_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type Color struct {
+	R, G, B uint8
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte{'#', "0123456789abcdef"[c.R>>4], "0123456789abcdef"[c.R&15]}, nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (c Color) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]uint8{"r": c.R, "g": c.G, "b": c.B})
+}
+
+// String implements fmt.Stringer
+func (c Color) String() string {
+	return "color"
+}
+
+type Status int
+
+// String implements fmt.Stringer
+func (s Status) String() string {
+	return [...]string{"new", "done"}[s]
+}
+
+var errBrokenText = errors.New("broken text")
+
+type BrokenText struct{}
+
+// MarshalText implements encoding.TextMarshaler
+func (BrokenText) MarshalText() ([]byte, error) {
+	return nil, errBrokenText
+}
+
+type HooksItem struct {
+	Color   Color     `db:"color"`
+	Status  Status    `db:"status"`
+	IP      net.IP    `db:"ip"`
+	Created time.Time `db:"created"`
+	Tint    *Color    `db:"tint"`
+}
+
+func getTestHooksItem() HooksItem {
+	return HooksItem{
+		Color:   Color{R: 0xab},
+		Status:  1,
+		IP:      net.IPv4(127, 0, 0, 1),
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestHooks_Default(t *testing.T) {
+	config := stom.NewConfig().WithPolicy(stom.PolicyExclude)
+	assert.Equal(t, []stom.Hook{stom.HookValuer, stom.HookZeroable, stom.HookToMappable}, config.Hooks())
+
+	item := getTestHooksItem()
+	doTest(t, stom.MustNewStomWith(HooksItem{}, config), item, map[string]interface{}{
+		"color":   item.Color,
+		"status":  item.Status,
+		"ip":      item.IP,
+		"created": item.Created,
+	})
+}
+
+func TestHooks_Order(t *testing.T) {
+	converter := stom.MustNewStomWith(HooksItem{}, stom.NewConfig().WithPolicy(stom.PolicyExclude))
+	item := getTestHooksItem()
+
+	// time.Time is Zeroable, it's recognized by HookZeroable first
+	textFirst := converter.WithHooks(stom.HookZeroable, stom.HookTextMarshaler, stom.HookJSONValue, stom.HookStringer)
+	doTest(t, textFirst, item, map[string]interface{}{
+		"color":   "#ab",
+		"status":  "done",
+		"ip":      "127.0.0.1",
+		"created": item.Created,
+	})
+
+	jsonFirst := converter.WithHooks(stom.HookJSONValue, stom.HookTextMarshaler, stom.HookStringer)
+	doTest(t, jsonFirst, item, map[string]interface{}{
+		"color":   map[string]interface{}{"r": 171.0, "g": 0.0, "b": 0.0},
+		"status":  "done",
+		"ip":      "127.0.0.1",
+		"created": "2020-01-02T03:04:05Z",
+	})
+
+	raw := converter.WithHooks(stom.HookJSONRaw)
+	item.Tint = &Color{R: 1}
+	doTest(t, raw, item, map[string]interface{}{
+		"color":   json.RawMessage(`{"b":0,"g":0,"r":171}`),
+		"status":  item.Status,
+		"ip":      item.IP,
+		"created": json.RawMessage(`"2020-01-02T03:04:05Z"`),
+		"tint":    json.RawMessage(`{"b":0,"g":0,"r":1}`),
+	})
+}
+
+func TestHooks_StructMode(t *testing.T) {
+	type palette struct {
+		Main Color `db:"main"`
+	}
+
+	// fields of Color are untagged, so they are named by Go names
+	config := stom.NewConfig().WithTags("db", stom.FieldName).WithStructMode(stom.StructFlatten)
+	assert.Equal(t, []string{"main.R", "main.G", "main.B"}, stom.MustNewStomWith(palette{}, config).TagValues())
+
+	// Color is recognized by a hook, so it's treated as a value
+	config = config.WithHooks(stom.HookStringer)
+	assert.Equal(t, []string{"main"}, stom.MustNewStomWith(palette{}, config).TagValues())
+	doTest(t, stom.MustNewStomWith(palette{}, config.WithStructMode(stom.StructNest)),
+		palette{}, map[string]interface{}{"main": "color"})
+}
+
+func TestHooks_Error(t *testing.T) {
+	type broken struct {
+		Text BrokenText `db:"text"`
+	}
+	converter := stom.MustNewStomWith(broken{}, stom.NewConfig()).WithHooks(stom.HookTextMarshaler)
+
+	_, err := converter.ToMap(broken{})
+	assert.True(t, errors.Is(err, errBrokenText))
+	assert.EqualError(t, err, "field Text (key text): broken text")
+}
+
+type PtrText struct {
+	V int
+}
+
+// MarshalText implements encoding.TextMarshaler with pointer receiver
+func (t *PtrText) MarshalText() ([]byte, error) {
+	return []byte("t" + strconv.Itoa(t.V)), nil
+}
+
+func TestHooks_PointerReceiver(t *testing.T) {
+	type texts struct {
+		Ref   *PtrText `db:"ref"`
+		Value PtrText  `db:"value"`
+	}
+
+	config := stom.NewConfig().WithHooks(stom.HookTextMarshaler).WithStructMode(stom.StructNest)
+	converter := stom.MustNewStomWith(texts{}, config)
+	item := texts{Ref: &PtrText{V: 2}, Value: PtrText{V: 3}}
+
+	expected := map[string]interface{}{"ref": "t2", "value": "t3"}
+	doTest(t, converter, item, expected)
+
+	// structures are converted the same way whether they are passed by value or by pointer
+	m, err := converter.ToMap(&item)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, m)
+}
+
+type PtrZero struct {
+	V int
+}
+
+// IsZero implements stom.Zeroable with pointer receiver
+func (z *PtrZero) IsZero() bool {
+	return z.V == 0
+}
+
+func TestHooks_PointerReceiverValueAndPointer(t *testing.T) {
+	type zeroes struct {
+		Z PtrZero `db:"z"`
+	}
+
+	config := stom.NewConfig().WithPolicy(stom.PolicyExclude)
+	item := zeroes{}
+
+	byValue, err := stom.ConvertToMapWith(item, config)
+	assert.NoError(t, err)
+	byPointer, err := stom.ConvertToMapWith(&item, config)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{}, byValue)
+	assert.Equal(t, byPointer, byValue)
+}
@@ -0,0 +1,216 @@
+package stom_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type Celsius float64
+
+// MarshalJSON implements json.Marshaler
+func (c Celsius) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"celsius":%g}`, float64(c))), nil
+}
+
+type Level int
+
+// MarshalText implements encoding.TextMarshaler
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("*", int(l))), nil
+}
+
+type Counter struct {
+	N int
+}
+
+// MarshalJSON implements json.Marshaler with pointer receiver
+func (c *Counter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.N * 10)
+}
+
+type JSONBase struct {
+	ID      int    `json:"id"`
+	Created string `json:"created,omitempty"`
+	Shadow  string
+}
+
+type JSONNamed struct {
+	Value string `json:"value"`
+}
+
+// jsonHidden is unexported, but its exported fields are promoted
+type jsonHidden struct {
+	Hidden string `json:"hidden"`
+}
+
+type JSONItem struct {
+	JSONBase
+	jsonHidden
+	*JSONNamed `json:"named"`
+
+	Name       string `json:"name"`
+	Shadow     string `json:"Shadow"`
+	Untagged   float32
+	Price      float64             `json:"price,string"`
+	Count      *int                `json:"count,string"`
+	Title      string              `json:"title,string"`
+	Flag       bool                `json:",string"`
+	Skipped    string              `json:"-"`
+	Dash       string              `json:"-,"`
+	Empty      []string            `json:"empty,omitempty"`
+	Nil        []string            `json:"nil"`
+	Tags       []string            `json:"tags"`
+	Bytes      []byte              `json:"bytes"`
+	Matrix     [2][2]uint8         `json:"matrix"`
+	Attrs      map[int]Level       `json:"attrs"`
+	Any        interface{}         `json:"any"`
+	Temp       Celsius             `json:"temp"`
+	Level      Level               `json:"level"`
+	Counter    Counter             `json:"counter"`
+	When       time.Time           `json:"when"`
+	Ptr        *JSONNamed          `json:"ptr,omitempty"`
+	Nested     map[string]JSONBase `json:"nested"`
+	Broken     string              `json:"broken"`
+	unexported int
+}
+
+func getTestJSONItem() JSONItem {
+	count := 42
+
+	return JSONItem{
+		JSONBase:   JSONBase{ID: 1, Shadow: "base"},
+		JSONNamed:  &JSONNamed{Value: "named"},
+		jsonHidden: jsonHidden{Hidden: "hidden"},
+		Name:       "name <&>",
+		Shadow:     "outer",
+		Untagged:   0.1,
+		Price:      1e21,
+		Count:      &count,
+		Title:      "title",
+		Flag:       true,
+		Skipped:    "skipped",
+		Dash:       "dash",
+		Empty:      []string{},
+		Tags:       []string{"a", "b"},
+		Bytes:      []byte("bytes"),
+		Matrix:     [2][2]uint8{{1, 2}, {3, 4}},
+		Attrs:      map[int]Level{1: 1, 3: 3},
+		Any:        map[string]interface{}{"x": []int{1}},
+		Temp:       36.6,
+		Level:      2,
+		Counter:    Counter{N: 5},
+		When:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Nested:     map[string]JSONBase{"k": {ID: 2, Created: "now"}},
+		Broken:     "\xffbroken\xfe\xfd",
+		unexported: 1,
+	}
+}
+
+func jsonRoundTrip(t *testing.T, v interface{}) map[string]interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected error from json.Marshal: %s", err.Error())
+	}
+
+	var expected map[string]interface{}
+	if err := json.Unmarshal(b, &expected); err != nil {
+		t.Fatalf("unexpected error from json.Unmarshal: %s", err.Error())
+	}
+
+	return expected
+}
+
+func TestJSONCompat_RoundTrip(t *testing.T) {
+	item := getTestJSONItem()
+	config := stom.NewConfig().WithTag("db").WithNaming(stom.SnakeCase).WithJSONCompat(true)
+
+	doTest(t, stom.MustNewStomWith(JSONItem{}, config), item, jsonRoundTrip(t, item))
+	doTest(t, stom.MustNewStomWith(JSONItem{}, config), &item, jsonRoundTrip(t, &item))
+
+	stom.SetJSONCompat(true)
+	defer stom.SetJSONCompat(false)
+	doTest(t, stom.ToMapperFunc(stom.ConvertToMap), &item, jsonRoundTrip(t, &item))
+
+	empty := JSONItem{}
+	doTest(t, stom.ToMapperFunc(stom.ConvertToMap), empty, jsonRoundTrip(t, empty))
+}
+
+func TestJSONCompat_TagValues(t *testing.T) {
+	converter := stom.MustNewStom(JSONItem{}).WithJSONCompat(true)
+
+	assert.ElementsMatch(t, []string{
+		"id", "created", "hidden", "named", "name", "Shadow", "Untagged", "price", "count", "title", "Flag", "-",
+		"empty", "nil", "tags", "bytes", "matrix", "attrs", "any", "temp", "level", "counter",
+		"when", "ptr", "nested", "broken",
+	}, converter.TagValues())
+	assert.True(t, converter.Config().JSONCompat())
+	assert.False(t, converter.WithJSONCompat(false).Config().JSONCompat())
+}
+
+type JSONMarshalerItem struct {
+	ID int `json:"id"`
+}
+
+// MarshalJSON implements json.Marshaler, it replaces both json.Marshal and ToMap()
+func (i JSONMarshalerItem) MarshalJSON() ([]byte, error) {
+	return []byte(`{"custom":1}`), nil
+}
+
+// ToMap implements ToMappable, it's ignored in encoding/json compatibility mode
+func (i JSONMarshalerItem) ToMap() (map[string]interface{}, error) {
+	return map[string]interface{}{"tomap": 1}, nil
+}
+
+type JSONArrayItem struct{}
+
+// MarshalJSON implements json.Marshaler returning not an object
+func (i JSONArrayItem) MarshalJSON() ([]byte, error) {
+	return []byte(`[1]`), nil
+}
+
+func TestJSONCompat_Marshaler(t *testing.T) {
+	config := stom.NewConfig().WithJSONCompat(true)
+
+	doTest(t, stom.MustNewStomWith(JSONMarshalerItem{}, config), JSONMarshalerItem{ID: 1}, map[string]interface{}{"custom": float64(1)})
+
+	_, err := stom.ConvertToMapWith(JSONArrayItem{}, config)
+	assert.EqualError(t, err, "json representation of stom_test.JSONArrayItem is not an object")
+}
+
+type JSONCycle struct {
+	Next *JSONCycle `json:"next"`
+}
+
+type JSONUnsupported struct {
+	Value float64     `json:"value"`
+	Ch    interface{} `json:"ch,omitempty"`
+	Func  func()      `json:"-"`
+	Cycle *JSONCycle  `json:"cycle,omitempty"`
+}
+
+func TestJSONCompat_Errors(t *testing.T) {
+	config := stom.NewConfig().WithJSONCompat(true)
+
+	_, err := stom.ConvertToMapWith(JSONUnsupported{Value: math.NaN()}, config)
+	assert.EqualError(t, err, "field Value (key value): unsupported value NaN")
+
+	_, err = stom.ConvertToMapWith(JSONUnsupported{Ch: make(chan int)}, config)
+	assert.EqualError(t, err, "field Ch (key ch): unsupported type chan int")
+
+	cycle := &JSONCycle{}
+	cycle.Next = cycle
+	_, err = stom.ConvertToMapWith(JSONUnsupported{Cycle: cycle}, config)
+
+	var fieldErr *stom.FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "Cycle.Next", fieldErr.Path)
+	assert.EqualError(t, fieldErr.Err, "encountered a cycle via *stom_test.JSONCycle")
+}
@@ -0,0 +1,190 @@
+package stom_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+// VendorProfile and VendorUser stand for types of a third-party package that cannot be tagged
+type VendorProfile struct {
+	Bio     string
+	Website *string
+}
+
+type VendorBase struct {
+	ID int
+}
+
+type VendorUser struct {
+	VendorBase
+	Name     string
+	Email    *string
+	Password string
+	Profile  VendorProfile
+	Legacy   string `db:"legacy"`
+}
+
+type VendorUserRow struct {
+	*VendorUser `db:",inline"`
+	Role        string `db:"role"`
+}
+
+func getVendorMapping() *stom.Mapping {
+	return stom.NewMapping().
+		Field("ID", "id").
+		Field("Name", "user_name", "omitempty").
+		Field("Email", "email").
+		Policy("Email", stom.PolicyExclude).
+		Skip("Password").
+		Field("Profile", "profile").
+		Field("Profile.Bio", "bio").
+		Field("Profile.Website", "website")
+}
+
+func TestMapping_Register(t *testing.T) {
+	assert.NoError(t, stom.Register(VendorUser{}, getVendorMapping()))
+	defer stom.Unregister(VendorUser{})
+
+	website := "example.com"
+	user := VendorUser{
+		VendorBase: VendorBase{ID: 1},
+		Password:   "secret",
+		Profile:    VendorProfile{Bio: "bio", Website: &website},
+		Legacy:     "legacy",
+	}
+
+	config := stom.NewConfig().WithDefault("DEFAULT")
+	doTest(t, stom.MustNewStomWith(VendorUser{}, config), user, map[string]interface{}{
+		"id":      1,
+		"profile": user.Profile,
+		"legacy":  "legacy",
+	})
+
+	doTest(t, stom.MustNewStomWith(VendorUser{}, config.WithStructMode(stom.StructFlatten)), user, map[string]interface{}{
+		"id":              1,
+		"profile.bio":     "bio",
+		"profile.website": "example.com",
+		"legacy":          "legacy",
+	})
+
+	// mapping applies to the type wherever it appears
+	user.Profile.Website = nil
+	doTest(t, stom.ToMapperFunc(func(s interface{}) (map[string]interface{}, error) {
+		return stom.ConvertToMapWith(s, config)
+	}), VendorUserRow{VendorUser: &user, Role: "admin"}, map[string]interface{}{
+		"id":      1,
+		"profile": user.Profile,
+		"legacy":  "legacy",
+		"role":    "admin",
+	})
+
+	var actual VendorUser
+	assert.NoError(t, stom.ConvertFromMapWith(map[string]interface{}{"id": 2, "user_name": "name"}, &actual, config))
+	assert.Equal(t, VendorUser{VendorBase: VendorBase{ID: 2}, Name: "name"}, actual)
+}
+
+func TestMapping_OuterMappingWins(t *testing.T) {
+	assert.NoError(t, stom.Register(VendorUser{}, getVendorMapping()))
+	defer stom.Unregister(VendorUser{})
+
+	assert.NoError(t, stom.Register(VendorUserRow{}, stom.NewMapping().
+		Field("VendorUser.Name", "name").
+		Field("ID", "user_id")))
+	defer stom.Unregister(VendorUserRow{})
+
+	converter := stom.MustNewStomWith(VendorUserRow{}, stom.NewConfig())
+	assert.Equal(t, []string{"user_id", "name", "email", "profile", "legacy", "role"}, converter.TagValues())
+}
+
+func TestMapping_InvalidPaths(t *testing.T) {
+	assert.EqualError(t, stom.Register(VendorUser{}, stom.NewMapping().Field("Missing", "missing")),
+		"field Missing of type stom_test.VendorUser is not found")
+	assert.EqualError(t, stom.Register(VendorUser{}, stom.NewMapping().Field("Name.First", "first")),
+		"field Name.First of type stom_test.VendorUser is not found: string is not a struct")
+	assert.EqualError(t, stom.Register(OptionsItem{}, stom.NewMapping().Field("Contact.phone", "phone")),
+		"field Contact.phone of type stom_test.OptionsItem is not found")
+	assert.Error(t, stom.Register(123, stom.NewMapping()))
+
+	type private struct {
+		hidden int
+	}
+	assert.EqualError(t, stom.Register(private{}, stom.NewMapping().Field("hidden", "hidden")),
+		"field hidden of type stom_test.private is unexported")
+}
+
+func TestMapping_Load(t *testing.T) {
+	assert.NoError(t, stom.RegisterType("vendor.user", VendorUser{}))
+	assert.NoError(t, stom.RegisterType("vendor.profile", &VendorProfile{}))
+	assert.Error(t, stom.RegisterType("number", 1))
+
+	defer stom.Unregister(VendorUser{})
+	defer stom.Unregister(VendorProfile{})
+
+	assert.NoError(t, stom.LoadMappings(strings.NewReader(`[
+		{
+			"type": "vendor.user",
+			"fields": [
+				{"path": "ID", "key": "user_id"},
+				{"path": "Name", "options": ["omitempty"]},
+				{"path": "Email", "key": "email", "policy": "exclude"},
+				{"path": "Password", "skip": true},
+				{"path": "Profile", "key": "profile", "options": ["nest"]},
+				{"path": "Legacy", "options": ["omitempty"]}
+			]
+		},
+		{
+			"type": "vendor.profile",
+			"fields": [{"path": "Bio", "key": "bio"}]
+		}
+	]`)))
+
+	converter := stom.MustNewStomWith(VendorUser{}, stom.NewConfig())
+	doTest(t, converter, VendorUser{
+		VendorBase: VendorBase{ID: 1},
+		Password:   "secret",
+		Profile:    VendorProfile{Bio: "bio"},
+	}, map[string]interface{}{
+		"user_id": 1,
+		"profile": map[string]interface{}{"bio": "bio"},
+	})
+
+	// options without key keep the key from tags
+	doTest(t, converter, VendorUser{Name: "name", Legacy: "legacy"}, map[string]interface{}{
+		"user_id": 0,
+		"Name":    "name",
+		"profile": map[string]interface{}{"bio": ""},
+		"legacy":  "legacy",
+	})
+}
+
+func TestMapping_LoadErrors(t *testing.T) {
+	assert.NoError(t, stom.RegisterType("vendor.user", VendorUser{}))
+
+	err := stom.LoadMappings(strings.NewReader(`[
+		{"type": "vendor.user", "fields": [
+			{"path": "Nmae", "key": "name"},
+			{"path": "ID", "key": "id"},
+			{"path": "Profile.Bio.Text"},
+			{"path": "Email", "policy": "never"}
+		]},
+		{"type": "vendor.missing", "fields": [{"path": "ID"}]}
+	]`))
+
+	var mappingErr *stom.MappingError
+	assert.True(t, errors.As(err, &mappingErr))
+	assert.EqualError(t, err, "4 mapping errors: "+
+		"unknown policy never of field Email of type vendor.user; "+
+		"field Nmae of type stom_test.VendorUser is not found; "+
+		"field Profile.Bio.Text of type stom_test.VendorUser is not found: string is not a struct; "+
+		"type vendor.missing is not registered")
+
+	// nothing is registered if there are errors
+	assert.Equal(t, []string{"legacy"}, stom.MustNewStomWith(VendorUser{}, stom.NewConfig()).TagValues())
+
+	err = stom.LoadMappings(strings.NewReader(`[{"type": "vendor.user", "fields": [{"path": "ID", "name": "id"}]}]`))
+	assert.EqualError(t, err, `cannot decode mappings: json: unknown field "name"`)
+}
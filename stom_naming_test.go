@@ -0,0 +1,118 @@
+package stom_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaming_Strategies(t *testing.T) {
+	cases := map[string][]string{
+		"UserID":       {"user_id", "userId", "user-id", "userid"},
+		"HTTPServerID": {"http_server_id", "httpServerId", "http-server-id", "httpserverid"},
+		"ID":           {"id", "id", "id", "id"},
+		"Name":         {"name", "name", "name", "name"},
+		"Address2City": {"address2_city", "address2City", "address2-city", "address2city"},
+		"Snake_Case":   {"snake_case", "snakeCase", "snake-case", "snake_case"},
+		"UserIDs":      {"user_ids", "userIds", "user-ids", "userids"},
+		"IDs":          {"ids", "ids", "ids", "ids"},
+		"URLsByID":     {"urls_by_id", "urlsById", "urls-by-id", "urlsbyid"},
+		"NameÜber":     {"name_über", "nameÜber", "name-über", "nameüber"},
+		"Statuses":     {"statuses", "statuses", "statuses", "statuses"},
+	}
+
+	strategies := []stom.NamingStrategy{stom.SnakeCase, stom.CamelCase, stom.KebabCase, stom.LowerCase}
+
+	for fieldName, expecteds := range cases {
+		for i, strategy := range strategies {
+			assert.Equal(t, expecteds[i], strategy.Name(fieldName), "field %s, strategy %d", fieldName, i)
+		}
+	}
+}
+
+type NamingItem struct {
+	UserID    int `db:"id"`
+	FirstName string
+	LastName  string `db:"-"`
+	HTTPPort  int
+	secret    string
+}
+
+func TestNaming_UntaggedFields(t *testing.T) {
+	converter := stom.MustNewStomWith(NamingItem{}, stom.NewConfig().WithNaming(stom.SnakeCase))
+
+	assert.Equal(t, []string{"id", "first_name", "http_port"}, converter.TagValues())
+
+	item := NamingItem{UserID: 1, FirstName: "first", LastName: "last", HTTPPort: 80, secret: "secret"}
+	doTest(t, converter, item, map[string]interface{}{"id": 1, "first_name": "first", "http_port": 80})
+
+	upper := stom.NamingFunc(strings.ToUpper)
+	doTest(t, converter.WithNaming(upper), item, map[string]interface{}{"id": 1, "FIRSTNAME": "first", "HTTPPORT": 80})
+	doTest(t, converter.WithTags("json", stom.FieldName).WithNaming(stom.KebabCase), item,
+		map[string]interface{}{"user-id": 1, "first-name": "first", "last-name": "last", "http-port": 80})
+}
+
+type NamingEmbedded struct {
+	ID   int
+	Name string
+}
+
+type NamingOuter struct {
+	NamingEmbedded
+	ItemName string `db:"name"`
+	Id       int
+}
+
+func TestNaming_Dominance(t *testing.T) {
+	config := stom.NewConfig().WithNaming(stom.LowerCase)
+
+	// "id" is ambiguous between untagged fields on the same depth,
+	// tagged "name" wins over named one
+	doTest(t, stom.MustNewStomWith(NamingOuter{}, config), NamingOuter{
+		NamingEmbedded: NamingEmbedded{ID: 1, Name: "embedded"},
+		ItemName:       "outer",
+		Id:             2,
+	}, map[string]interface{}{"id": 2, "name": "outer"})
+}
+
+// sliceNaming is not comparable, analysis of types with it is not cached
+type sliceNaming []string
+
+func (n sliceNaming) Name(fieldName string) string {
+	return n[0] + fieldName
+}
+
+func TestNaming_NotComparable(t *testing.T) {
+	converter := stom.MustNewStomWith(NamingItem{}, stom.NewConfig().WithNaming(sliceNaming{"x_"}))
+
+	assert.Equal(t, []string{"id", "x_FirstName", "x_HTTPPort"}, converter.TagValues())
+	assert.Equal(t, []string{"id", "y_FirstName", "y_HTTPPort"}, converter.WithNaming(sliceNaming{"y_"}).TagValues())
+}
+
+func TestNaming_FuncCached(t *testing.T) {
+	calls := 0
+	counting := stom.NamingFunc(func(fieldName string) string {
+		calls++
+		return strings.ToLower(fieldName)
+	})
+	config := stom.NewConfig().WithNaming(counting)
+	item := NamingItem{UserID: 1, FirstName: "first", HTTPPort: 80}
+	expected := map[string]interface{}{"id": 1, "firstname": "first", "httpport": 80}
+
+	m, err := stom.ConvertToMapWith(item, config)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, m)
+
+	analyzed := calls
+	m, err = stom.ConvertToMapWith(item, config)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, m)
+	assert.Equal(t, analyzed, calls)
+
+	// strategies made from different functions don't share analyzed types
+	m, err = stom.ConvertToMapWith(item, config.WithNaming(stom.NamingFunc(strings.ToUpper)))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": 1, "FIRSTNAME": "first", "HTTPPORT": 80}, m)
+}
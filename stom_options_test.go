@@ -0,0 +1,104 @@
+package stom_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type Address struct {
+	City   string `db:"city"`
+	Street string `db:"street,omitempty"`
+}
+
+type Contact struct {
+	Phone string `db:"phone"`
+}
+
+type OptionsItem struct {
+	ID       int            `db:"id,string"`
+	Name     string         `db:"name,omitempty"`
+	Price    float64        `db:"price,omitempty,string"`
+	Visible  bool           `db:"visible,string"`
+	Rating   sql.NullInt64  `db:"rating,string"`
+	Author   sql.NullString `db:"author,omitempty"`
+	Dash     int            `db:"-,"`
+	Ignored  int            `db:"-"`
+	Untitled int            `db:",omitempty"`
+	Address  Address        `db:",inline"`
+	Contact  *Contact       `db:"contact,inline"`
+}
+
+func TestOptions_TagValues(t *testing.T) {
+	tagValues := stom.MustNewStom(OptionsItem{}).SetTag("db").TagValues()
+
+	assert.ElementsMatch(t, []string{
+		"id", "name", "price", "visible", "rating", "author", "-", "Untitled", "city", "street", "phone",
+	}, tagValues)
+}
+
+func TestOptions_ToMap(t *testing.T) {
+	converter := stom.MustNewStom(OptionsItem{}).
+		SetTag("db").
+		SetPolicy(stom.PolicyUseDefault).
+		SetDefault("DEFAULT")
+
+	doTest(t, converter, OptionsItem{
+		ID:      1,
+		Name:    "name",
+		Price:   1.5,
+		Visible: true,
+		Rating:  sql.NullInt64{Int64: 5, Valid: true},
+		Author:  sql.NullString{String: "author", Valid: true},
+		Dash:    2,
+		Ignored: 3,
+		Address: Address{City: "city", Street: "street"},
+		Contact: &Contact{Phone: "123"},
+	}, map[string]interface{}{
+		"id":      "1",
+		"name":    "name",
+		"price":   "1.5",
+		"visible": "true",
+		"rating":  "5",
+		"author":  sql.NullString{String: "author", Valid: true},
+		"-":       2,
+		"city":    "city",
+		"street":  "street",
+		"phone":   "123",
+	})
+
+	doTest(t, converter, OptionsItem{Contact: &Contact{}}, map[string]interface{}{
+		"id":      "0",
+		"visible": "false",
+		"rating":  "DEFAULT",
+		"-":       0,
+		"city":    "",
+		"phone":   "",
+	})
+}
+
+func TestOptions_FromMap(t *testing.T) {
+	var actual OptionsItem
+	err := stom.MustNewStom(OptionsItem{}).SetTag("db").FromMap(map[string]interface{}{
+		"id":      "1",
+		"price":   "1.5",
+		"visible": "true",
+		"rating":  "5",
+		"-":       2,
+		"city":    "city",
+		"phone":   "123",
+	}, &actual)
+
+	assert.NoError(t, err)
+	assert.Equal(t, OptionsItem{
+		ID:      1,
+		Price:   1.5,
+		Visible: true,
+		Rating:  sql.NullInt64{Int64: 5, Valid: true},
+		Dash:    2,
+		Address: Address{City: "city"},
+		Contact: &Contact{Phone: "123"},
+	}, actual)
+}
@@ -0,0 +1,160 @@
+package stom_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type PolicyItem struct {
+	ID      int               `db:"id"`
+	Name    string            `db:"name"`
+	Active  bool              `db:"active"`
+	Price   *float64          `db:"price"`
+	Tags    []string          `db:"tags"`
+	Attrs   map[string]string `db:"attrs"`
+	Address Address           `db:"address"`
+}
+
+func TestOmitZeroPolicy(t *testing.T) {
+	converter := stom.MustNewStomWith(PolicyItem{}, stom.NewConfig().WithPolicy(stom.PolicyOmitZero))
+
+	doTest(t, converter, PolicyItem{Tags: []string{}, Attrs: map[string]string{}}, map[string]interface{}{})
+
+	price := 0.0
+	doTest(t, converter, PolicyItem{
+		ID:      1,
+		Active:  true,
+		Price:   &price,
+		Tags:    []string{"tag"},
+		Address: Address{City: "city"},
+	}, map[string]interface{}{
+		"id":      1,
+		"active":  true,
+		"price":   0.0,
+		"tags":    []string{"tag"},
+		"address": Address{City: "city"},
+	})
+}
+
+func TestErrorPolicy(t *testing.T) {
+	converter := stom.MustNewStomWith(PolicyItem{}, stom.NewConfig().WithPolicy(stom.PolicyError))
+
+	_, err := converter.ToMap(PolicyItem{ID: 1})
+	assert.True(t, errors.Is(err, stom.ErrNilValue))
+	assert.EqualError(t, err, "field Price (key price): value is nil")
+
+	price := 1.0
+	doTest(t, converter, PolicyItem{Price: &price}, map[string]interface{}{
+		"id":      0,
+		"name":    "",
+		"active":  false,
+		"price":   1.0,
+		"tags":    []string(nil),
+		"attrs":   map[string]string(nil),
+		"address": Address{},
+	})
+}
+
+func TestKeepNilPolicy(t *testing.T) {
+	defer restorePackageSettings(stom.PackageConfig())
+
+	stom.SetTag("db")
+	stom.SetPolicy(stom.PolicyKeepNil)
+	stom.SetDefault("SomeDefault")
+
+	doTest(t, stom.ToMapperFunc(stom.ConvertToMap), getTestItems()[2], map[string]interface{}{
+		"id":       3,
+		"name":     "item_3",
+		"number":   33,
+		"created":  nil,
+		"updated":  nil,
+		"price":    3333.0,
+		"discount": nil,
+		"reserved": nil,
+		"points":   nil,
+		"rating":   nil,
+		"visible":  false,
+	})
+}
+
+type FieldPolicyItem struct {
+	ID       int             `db:"id"`
+	Discount *float64        `db:"discount,default=0"`
+	Rating   sql.NullFloat64 `db:"rating,policy=exclude"`
+	Points   sql.NullInt64   `db:"points,default=10"`
+	Label    *string         `db:"label,default=none,policy=keepnil"`
+	Created  time.Time       `db:"created,default=2020-01-02T00:00:00Z"`
+	Note     *string         `db:"note"`
+}
+
+func TestFieldPolicy_ToMap(t *testing.T) {
+	converter := stom.MustNewStomWith(FieldPolicyItem{}, stom.NewConfig().
+		WithPolicy(stom.PolicyExclude).
+		WithDefault("DEFAULT"))
+
+	doTest(t, converter, FieldPolicyItem{ID: 1}, map[string]interface{}{
+		"id":       1,
+		"discount": 0.0,
+		"points":   sql.NullInt64{Int64: 10, Valid: true},
+		"label":    nil,
+		"created":  time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+
+	doTest(t, converter.WithPolicy(stom.PolicyUseDefault), FieldPolicyItem{ID: 1}, map[string]interface{}{
+		"id":       1,
+		"discount": 0.0,
+		"points":   sql.NullInt64{Int64: 10, Valid: true},
+		"label":    nil,
+		"created":  time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		"note":     "DEFAULT",
+	})
+}
+
+func TestFieldPolicy_FromMap(t *testing.T) {
+	discount := 5.0
+
+	var actual FieldPolicyItem
+	converter := stom.MustNewStomWith(FieldPolicyItem{}, stom.NewConfig())
+
+	err := converter.WithDefault("DEFAULT").FromMap(map[string]interface{}{
+		"discount": 0.0,
+		"note":     "DEFAULT",
+	}, &actual)
+	assert.NoError(t, err)
+	assert.Nil(t, actual.Discount)
+	assert.Nil(t, actual.Note)
+
+	err = converter.FromMap(map[string]interface{}{"discount": discount}, &actual)
+	assert.NoError(t, err)
+	assert.Equal(t, &discount, actual.Discount)
+}
+
+func TestFieldPolicy_InvalidOptions(t *testing.T) {
+	type badDefault struct {
+		Count int `db:"count,default=many"`
+	}
+	type badPolicy struct {
+		Nested struct {
+			Name *string `db:"name,policy=sometimes"`
+		} `db:"nested,nest"`
+	}
+	type unsupported struct {
+		Tags []string `db:"tags,default=a"`
+	}
+
+	config := stom.NewConfig()
+
+	_, err := stom.NewStomWith(badDefault{}, config)
+	assert.EqualError(t, err, `field Count (key count): invalid default value "many" for type int`)
+
+	_, err = stom.ConvertToMapWith(badPolicy{}, config)
+	assert.EqualError(t, err, "field Nested.Name (key nested.name): unknown policy sometimes")
+
+	_, err = stom.NewStomWith(unsupported{}, config)
+	assert.EqualError(t, err, "field Tags (key tags): default value is not supported for type []string")
+}
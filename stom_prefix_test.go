@@ -0,0 +1,73 @@
+package stom_test
+
+import (
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type Author struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+type Book struct {
+	ID    int    `db:"id"`
+	Title string `db:"title"`
+}
+
+type BookRow struct {
+	Book
+	*Author   `db:",prefix=author_"`
+	Publisher Author  `db:"publisher,flatten,prefix=pub_"`
+	Address   Address `db:"address,flatten"`
+}
+
+func TestPrefix_TagValues(t *testing.T) {
+	converter := stom.MustNewStom(BookRow{}).SetTag("db").SetSeparator(".")
+
+	assert.ElementsMatch(t, []string{
+		"id", "title", "author_id", "author_name", "pub_id", "pub_name", "address.city", "address.street",
+	}, converter.TagValues())
+}
+
+func TestPrefix_ToMap(t *testing.T) {
+	converter := stom.MustNewStom(BookRow{}).SetTag("db").SetSeparator(".")
+
+	row := BookRow{
+		Book:      Book{ID: 1, Title: "title"},
+		Author:    &Author{ID: 2, Name: "author"},
+		Publisher: Author{ID: 3, Name: "publisher"},
+		Address:   Address{City: "city"},
+	}
+
+	doTest(t, converter, row, map[string]interface{}{
+		"id":           1,
+		"title":        "title",
+		"author_id":    2,
+		"author_name":  "author",
+		"pub_id":       3,
+		"pub_name":     "publisher",
+		"address.city": "city",
+	})
+}
+
+func TestPrefix_FromMap(t *testing.T) {
+	converter := stom.MustNewStom(BookRow{}).SetTag("db").SetSeparator(".")
+
+	var actual BookRow
+	err := converter.FromMap(map[string]interface{}{
+		"id":          1,
+		"author_id":   2,
+		"author_name": "author",
+		"pub_name":    "publisher",
+	}, &actual)
+
+	assert.NoError(t, err)
+	assert.Equal(t, BookRow{
+		Book:      Book{ID: 1},
+		Author:    &Author{ID: 2, Name: "author"},
+		Publisher: Author{Name: "publisher"},
+	}, actual)
+}
@@ -0,0 +1,149 @@
+package stom_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type InvoiceLine struct {
+	SKU      string   `db:"sku"`
+	Quantity int      `db:"quantity"`
+	Price    *float64 `db:"price"`
+}
+
+type Invoice struct {
+	ID        int            `db:"id"`
+	Lines     []InvoiceLine  `db:"lines"`
+	Refs      []*InvoiceLine `db:"refs"`
+	Addresses [2]Address     `db:"addresses"`
+	Notes     []string       `db:"notes"`
+}
+
+func getTestInvoice() Invoice {
+	price := 9.5
+	return Invoice{
+		ID: 1,
+		Lines: []InvoiceLine{
+			{SKU: "a", Quantity: 1, Price: &price},
+			{SKU: "b", Quantity: 2},
+		},
+		Refs:      []*InvoiceLine{nil, {SKU: "c"}},
+		Addresses: [2]Address{{City: "Berlin"}, {City: "Paris", Street: "Rivoli"}},
+		Notes:     []string{"fragile"},
+	}
+}
+
+func TestSliceMode_AsValue(t *testing.T) {
+	invoice := getTestInvoice()
+	doTest(t, stom.MustNewStomWith(Invoice{}, stom.NewConfig()), invoice, map[string]interface{}{
+		"id":        1,
+		"lines":     invoice.Lines,
+		"refs":      invoice.Refs,
+		"addresses": invoice.Addresses,
+		"notes":     invoice.Notes,
+	})
+}
+
+func TestSliceMode_Nest(t *testing.T) {
+	converter := stom.MustNewStomWith(Invoice{}, stom.NewConfig()).SetSliceMode(stom.SliceNest).SetPolicy(stom.PolicyExclude)
+
+	doTest(t, converter, getTestInvoice(), map[string]interface{}{
+		"id": 1,
+		"lines": []map[string]interface{}{
+			{"sku": "a", "quantity": 1, "price": 9.5},
+			{"sku": "b", "quantity": 2},
+		},
+		"refs": []map[string]interface{}{
+			nil,
+			{"sku": "c", "quantity": 0},
+		},
+		"addresses": []map[string]interface{}{
+			{"city": "Berlin"},
+			{"city": "Paris", "street": "Rivoli"},
+		},
+		"notes": []string{"fragile"},
+	})
+
+	doTest(t, converter, Invoice{}, map[string]interface{}{
+		"id":        0,
+		"addresses": []map[string]interface{}{{"city": ""}, {"city": ""}},
+		"notes":     []string(nil),
+	})
+}
+
+func TestSliceMode_Flatten(t *testing.T) {
+	converter := stom.MustNewStomWith(Invoice{}, stom.NewConfig().
+		WithSliceMode(stom.SliceFlatten).
+		WithPolicy(stom.PolicyExclude))
+	assert.Equal(t, []string{"id", "lines", "refs", "addresses", "notes"}, converter.TagValues())
+
+	doTest(t, converter, getTestInvoice(), map[string]interface{}{
+		"id":                 1,
+		"lines.0.sku":        "a",
+		"lines.0.quantity":   1,
+		"lines.0.price":      9.5,
+		"lines.1.sku":        "b",
+		"lines.1.quantity":   2,
+		"refs.1.sku":         "c",
+		"refs.1.quantity":    0,
+		"addresses.0.city":   "Berlin",
+		"addresses.1.city":   "Paris",
+		"addresses.1.street": "Rivoli",
+		"notes":              []string{"fragile"},
+	})
+
+	doTest(t, converter.WithIndexBrackets(true).WithSeparator("_"), Invoice{Lines: []InvoiceLine{{SKU: "a"}}}, map[string]interface{}{
+		"id":                0,
+		"lines[0]_sku":      "a",
+		"lines[0]_quantity": 0,
+		"addresses[0]_city": "",
+		"addresses[1]_city": "",
+		"notes":             []string(nil),
+	})
+}
+
+func TestSliceMode_TagOptions(t *testing.T) {
+	type cart struct {
+		Lines []InvoiceLine `db:"lines,flatten"`
+		Saved []InvoiceLine `db:"saved,value"`
+	}
+
+	converter := stom.MustNewStomWith(cart{}, stom.NewConfig().WithSliceMode(stom.SliceNest).WithPolicy(stom.PolicyExclude))
+	lines := []InvoiceLine{{SKU: "a"}}
+	doTest(t, converter, cart{Lines: lines, Saved: lines}, map[string]interface{}{
+		"lines.0.sku":      "a",
+		"lines.0.quantity": 0,
+		"saved":            lines,
+	})
+}
+
+func TestSliceMode_Error(t *testing.T) {
+	converter := stom.MustNewStomWith(Invoice{}, stom.NewConfig()).SetSliceMode(stom.SliceNest).SetPolicy(stom.PolicyError)
+
+	price := 1.0
+	_, err := converter.ToMap(Invoice{Lines: []InvoiceLine{{Price: &price}, {}}})
+	assert.True(t, errors.Is(err, stom.ErrNilValue))
+	assert.EqualError(t, err, "field Lines.1.Price (key lines.1.price): value is nil")
+}
+
+func TestSliceMode_FromMap(t *testing.T) {
+	converter := stom.MustNewStomWith(Invoice{}, stom.NewConfig()).SetSliceMode(stom.SliceNest).SetPolicy(stom.PolicyExclude)
+
+	expected := getTestInvoice()
+	m, err := converter.ToMap(expected)
+	assert.NoError(t, err)
+
+	var actual Invoice
+	assert.NoError(t, converter.FromMap(m, &actual))
+	assert.Equal(t, expected, actual)
+
+	// maps decoded from JSON hold slices of interface{}
+	err = converter.FromMap(map[string]interface{}{
+		"lines": []interface{}{map[string]interface{}{"sku": "x"}, nil},
+	}, &actual)
+	assert.NoError(t, err)
+	assert.Equal(t, []InvoiceLine{{SKU: "x"}, {}}, actual.Lines)
+}
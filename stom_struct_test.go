@@ -0,0 +1,154 @@
+package stom_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type Customer struct {
+	ID       int       `db:"id"`
+	Address  Address   `db:"address"`
+	Shipping *Address  `db:"shipping"`
+	Billing  Address   `db:"billing,value"`
+	Created  time.Time `db:"created"`
+	Referrer *Customer `db:"referrer"`
+}
+
+func getTestCustomer() Customer {
+	return Customer{
+		ID:       1,
+		Address:  Address{City: "Berlin", Street: "Main"},
+		Shipping: &Address{City: "Hamburg"},
+		Billing:  Address{City: "Munich"},
+		Created:  time.Unix(10000, 0),
+		Referrer: &Customer{ID: 2},
+	}
+}
+
+func TestStructMode_AsValue(t *testing.T) {
+	converter := stom.MustNewStom(Customer{}).SetTag("db").SetPolicy(stom.PolicyExclude)
+
+	customer := getTestCustomer()
+	doTest(t, converter, customer, map[string]interface{}{
+		"id":       1,
+		"address":  customer.Address,
+		"shipping": *customer.Shipping,
+		"billing":  customer.Billing,
+		"created":  customer.Created,
+		"referrer": *customer.Referrer,
+	})
+}
+
+func TestStructMode_Nest(t *testing.T) {
+	converter := stom.MustNewStom(Customer{}).
+		SetTag("db").
+		SetPolicy(stom.PolicyUseDefault).
+		SetDefault(nil).
+		SetStructMode(stom.StructNest)
+
+	customer := getTestCustomer()
+	doTest(t, converter, customer, map[string]interface{}{
+		"id":       1,
+		"address":  map[string]interface{}{"city": "Berlin", "street": "Main"},
+		"shipping": map[string]interface{}{"city": "Hamburg"},
+		"billing":  customer.Billing,
+		"created":  customer.Created,
+		// self-referencing types are not nested to avoid infinite recursion
+		"referrer": *customer.Referrer,
+	})
+
+	customer.Shipping = nil
+	customer.Referrer = nil
+	doTest(t, converter, customer, map[string]interface{}{
+		"id":       1,
+		"address":  map[string]interface{}{"city": "Berlin", "street": "Main"},
+		"shipping": nil,
+		"billing":  customer.Billing,
+		"created":  customer.Created,
+		"referrer": nil,
+	})
+}
+
+func TestStructMode_Flatten(t *testing.T) {
+	converter := stom.MustNewStom(Customer{}).
+		SetTag("db").
+		SetPolicy(stom.PolicyExclude).
+		SetStructMode(stom.StructFlatten).
+		SetSeparator("_")
+
+	assert.ElementsMatch(t, []string{
+		"id", "address_city", "address_street", "shipping_city", "shipping_street", "billing", "created", "referrer",
+	}, converter.TagValues())
+
+	customer := getTestCustomer()
+	doTest(t, converter, customer, map[string]interface{}{
+		"id":             1,
+		"address_city":   "Berlin",
+		"address_street": "Main",
+		"shipping_city":  "Hamburg",
+		"billing":        customer.Billing,
+		"created":        customer.Created,
+		"referrer":       *customer.Referrer,
+	})
+}
+
+type Order struct {
+	ID       int      `db:"id"`
+	Address  Address  `db:"address,flatten"`
+	Customer Customer `db:"customer,nest"`
+}
+
+func TestStructMode_TagOptions(t *testing.T) {
+	defer restorePackageSettings(stom.PackageConfig())
+	stom.SetTag("db")
+	stom.SetPolicy(stom.PolicyExclude)
+	stom.SetSeparator(".")
+
+	for _, mode := range []stom.StructMode{stom.StructAsValue, stom.StructNest} {
+		stom.SetStructMode(mode)
+
+		order := Order{ID: 1, Address: Address{City: "Berlin"}, Customer: Customer{ID: 2}}
+		expected := map[string]interface{}{
+			"id":           1,
+			"address.city": "Berlin",
+			"customer": map[string]interface{}{
+				"id":      2,
+				"address": order.Customer.Address,
+				"billing": order.Customer.Billing,
+			},
+		}
+		if mode == stom.StructNest {
+			expected["customer"].(map[string]interface{})["address"] = map[string]interface{}{"city": ""}
+		}
+
+		doTest(t, stom.ToMapperFunc(stom.ConvertToMap), order, expected)
+	}
+}
+
+func TestStructMode_FromMap(t *testing.T) {
+	defer restorePackageSettings(stom.PackageConfig())
+	stom.SetTag("db")
+	stom.SetPolicy(stom.PolicyExclude)
+	stom.SetSeparator(".")
+	stom.SetStructMode(stom.StructNest)
+
+	var actual Order
+	err := stom.ConvertFromMap(map[string]interface{}{
+		"id":           1,
+		"address.city": "Berlin",
+		"customer": map[string]interface{}{
+			"id":       2,
+			"shipping": map[string]interface{}{"city": "Hamburg"},
+		},
+	}, &actual)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Order{
+		ID:       1,
+		Address:  Address{City: "Berlin"},
+		Customer: Customer{ID: 2, Shipping: &Address{City: "Hamburg"}},
+	}, actual)
+}
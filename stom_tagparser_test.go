@@ -0,0 +1,150 @@
+package stom_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type GormBase struct {
+	CreatedBy string `gorm:"column:created_by"`
+}
+
+type GormAudit struct {
+	UpdatedBy string `gorm:"column:by"`
+}
+
+type GormItem struct {
+	GormBase
+	ID       int       `gorm:"column:id;primaryKey"`
+	UserName string    `gorm:"type:varchar(100);COLUMN: user_name"`
+	Ignored  string    `gorm:"-"`
+	ReadOnly string    `gorm:"-:migration;column:read_only"`
+	Untagged string    `gorm:"type:text"`
+	Audit    GormAudit `gorm:"embedded;embeddedPrefix:updated_"`
+	Legacy   string    `db:"legacy"`
+}
+
+func TestTagParser_Gorm(t *testing.T) {
+	converter := stom.MustNewStom(GormItem{}).WithTagParsers(stom.GormTag, stom.StructTag("db"))
+
+	assert.Equal(t, []string{"created_by", "id", "user_name", "read_only", "updated_by", "legacy"}, converter.TagValues())
+	assert.Nil(t, converter.Tags())
+	assert.Equal(t, "", converter.Tag())
+
+	doTest(t, converter.WithPolicy(stom.PolicyExclude), GormItem{
+		GormBase: GormBase{CreatedBy: "creator"},
+		ID:       1,
+		UserName: "user",
+		Ignored:  "ignored",
+		ReadOnly: "read",
+		Untagged: "untagged",
+		Audit:    GormAudit{UpdatedBy: "updater"},
+		Legacy:   "legacy",
+	}, map[string]interface{}{
+		"created_by": "creator",
+		"id":         1,
+		"user_name":  "user",
+		"read_only":  "read",
+		"updated_by": "updater",
+		"legacy":     "legacy",
+	})
+
+	named := converter.WithTagParsers(stom.GormTag, stom.FieldNameTag).WithNaming(stom.SnakeCase)
+	assert.Equal(t, []string{"created_by", "id", "user_name", "read_only", "untagged", "updated_by", "legacy"}, named.TagValues())
+}
+
+type BSONItem struct {
+	ID      int    `bson:"_id"`
+	Name    string `bson:",omitempty"`
+	Skipped string `bson:"-"`
+	Address `bson:",inline"`
+	Other   string `db:"other"`
+}
+
+func TestTagParser_BSON(t *testing.T) {
+	converter := stom.MustNewStomWith(BSONItem{}, stom.NewConfig().WithTagParsers(stom.BSONTag, stom.StructTag("db")))
+
+	assert.Equal(t, []string{"_id", "name", "city", "street", "other"}, converter.TagValues())
+
+	doTest(t, converter, BSONItem{ID: 1, Address: Address{City: "city"}, Other: "other"}, map[string]interface{}{
+		"_id":   1,
+		"city":  "city",
+		"other": "other",
+	})
+}
+
+func TestTagParser_Custom(t *testing.T) {
+	// fields are recognized by "key" tag, keys are upper-cased
+	upper := stom.TagParserFunc(func(field reflect.StructField) (stom.ParsedTag, bool) {
+		key, ok := field.Tag.Lookup("key")
+		return stom.ParsedTag{Name: strings.ToUpper(key), Skip: key == "skip"}, ok
+	})
+
+	type item struct {
+		A int `key:"a"`
+		B int `key:"skip"`
+		C int `db:"c"`
+	}
+
+	stom.SetTagParsers(upper, stom.StructTag("db"))
+	defer stom.SetTag("db")
+
+	doTest(t, stom.ToMapperFunc(stom.ConvertToMap), item{A: 1, B: 2, C: 3}, map[string]interface{}{"A": 1, "c": 3})
+
+	tags := stom.NewConfig().WithTags("db", stom.FieldName).TagParsers()
+	assert.Equal(t, []stom.TagParser{stom.StructTag("db"), stom.FieldNameTag}, tags)
+}
+
+func TestTagParser_FreshChains(t *testing.T) {
+	type item struct {
+		A int `a:"first" b:"second"`
+	}
+
+	// chains are compared by values of parsers, so fresh chains share analysis of types
+	for i := 0; i < 3; i++ {
+		for tag, key := range map[string]string{"a": "first", "b": "second"} {
+			m, err := stom.ConvertToMapWith(item{A: i}, stom.NewConfig().WithTagParsers(stom.StructTag(tag)))
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{key: i}, m)
+		}
+
+		// functions cannot be compared, such chains are not cached
+		prefix := strings.Repeat("x", i)
+		m, err := stom.ConvertToMapWith(item{A: i}, stom.NewConfig().WithTagParsers(stom.TagParserFunc(func(f reflect.StructField) (stom.ParsedTag, bool) {
+			return stom.ParsedTag{Name: prefix + f.Name}, true
+		})))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{prefix + "A": i}, m)
+	}
+}
+
+func TestTagParser_NewTagParserCached(t *testing.T) {
+	type item struct {
+		A int
+	}
+
+	calls := 0
+	counting := stom.NewTagParser(func(f reflect.StructField) (stom.ParsedTag, bool) {
+		calls++
+		return stom.ParsedTag{Name: strings.ToLower(f.Name)}, true
+	})
+	config := stom.NewConfig().WithTagParsers(counting)
+
+	for i := 0; i < 3; i++ {
+		m, err := stom.ConvertToMapWith(item{A: i}, config)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": i}, m)
+	}
+	assert.Equal(t, 1, calls)
+
+	// parsers made from different functions don't share analyzed types
+	m, err := stom.ConvertToMapWith(item{A: 1}, config.WithTagParsers(stom.NewTagParser(func(f reflect.StructField) (stom.ParsedTag, bool) {
+		return stom.ParsedTag{Name: f.Name}, true
+	})))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"A": 1}, m)
+}
@@ -0,0 +1,113 @@
+package stom_test
+
+import (
+	"testing"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type CustomItem struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+// ToMap implements ToMappable interface replacing the default conversion
+func (this CustomItem) ToMap() (map[string]interface{}, error) {
+	return map[string]interface{}{"custom": this.ID}, nil
+}
+
+type ExtendedItem struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+// ToMap implements ToMappable interface extending the default conversion
+func (this ExtendedItem) ToMap() (map[string]interface{}, error) {
+	m, err := stom.Default(this)
+	if err != nil {
+		return nil, err
+	}
+	m["title"] = this.Name + "!"
+
+	return m, nil
+}
+
+func TestToMappable_BothModes(t *testing.T) {
+	stom.SetTag("db")
+	stom.SetForceReflect(false)
+
+	tomappers := []stom.ToMapper{
+		stom.ToMapperFunc(stom.ConvertToMap),
+		stom.MustNewStom(CustomItem{}),
+	}
+
+	for _, tomapper := range tomappers {
+		doTest(t, tomapper, CustomItem{ID: 1, Name: "name"}, map[string]interface{}{"custom": 1})
+	}
+}
+
+func TestToMappable_ForceReflect(t *testing.T) {
+	stom.SetTag("db")
+	stom.SetForceReflect(true)
+	defer stom.SetForceReflect(false)
+
+	expected := map[string]interface{}{"id": 1, "name": "name"}
+
+	doTest(t, stom.ToMapperFunc(stom.ConvertToMap), CustomItem{ID: 1, Name: "name"}, expected)
+
+	converter := stom.MustNewStom(CustomItem{})
+	doTest(t, converter, CustomItem{ID: 1, Name: "name"}, expected)
+
+	converter.SetForceReflect(false)
+	doTest(t, converter, CustomItem{ID: 1, Name: "name"}, map[string]interface{}{"custom": 1})
+}
+
+func TestToMappable_Default(t *testing.T) {
+	stom.SetTag("db")
+
+	expected := map[string]interface{}{"id": 1, "name": "name", "title": "name!"}
+
+	doTest(t, stom.ToMapperFunc(stom.ConvertToMap), ExtendedItem{ID: 1, Name: "name"}, expected)
+	doTest(t, stom.MustNewStom(ExtendedItem{}), ExtendedItem{ID: 1, Name: "name"}, expected)
+
+	m, err := stom.Default(CustomItem{ID: 1, Name: "name"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": 1, "name": "name"}, m)
+}
+
+type ConfiguredItem struct {
+	ID   int    `db:"id" json:"item_id"`
+	Name string `db:"name" json:"item_name"`
+}
+
+// ToMapWith implements ToMappableWith interface extending the default conversion with settings of the caller
+func (this ConfiguredItem) ToMapWith(config stom.Config) (map[string]interface{}, error) {
+	m, err := stom.DefaultWith(this, config)
+	if err != nil {
+		return nil, err
+	}
+	m["title"] = this.Name + "!"
+
+	return m, nil
+}
+
+func TestToMappable_WithConfig(t *testing.T) {
+	config := stom.NewConfig().WithTag("json")
+	item := ConfiguredItem{ID: 1, Name: "name"}
+	expected := map[string]interface{}{"item_id": 1, "item_name": "name", "title": "name!"}
+
+	doTest(t, stom.MustNewStomWith(ConfiguredItem{}, config), item, expected)
+	doTest(t, stom.ToMapperFunc(func(s interface{}) (map[string]interface{}, error) {
+		return stom.ConvertToMapWith(s, config)
+	}), item, expected)
+
+	// nested values get settings of the caller too
+	type wrapper struct {
+		Item ConfiguredItem `json:"item"`
+	}
+	doTest(t, stom.MustNewStomWith(wrapper{}, config), wrapper{Item: item}, map[string]interface{}{"item": expected})
+
+	doTest(t, stom.MustNewStomWith(ConfiguredItem{}, config.WithForceReflect(true)), item,
+		map[string]interface{}{"item_id": 1, "item_name": "name"})
+}
@@ -0,0 +1,174 @@
+package stom_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elgris/stom"
+	"github.com/stretchr/testify/assert"
+)
+
+type ItemID [4]byte
+
+func (id ItemID) String() string {
+	return fmt.Sprintf("%x", id[:])
+}
+
+type Money struct {
+	Cents int64
+}
+
+type ConvertedItem struct {
+	ID      ItemID       `db:"id"`
+	Parent  *ItemID      `db:"parent"`
+	Created time.Time    `db:"created"`
+	Updated *time.Time   `db:"updated"`
+	Price   Money        `db:"price,nest"`
+	Label   fmt.Stringer `db:"label"`
+	Count   int          `db:"count,string"`
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+func unixSeconds(v reflect.Value) (interface{}, error) {
+	t := v.Interface().(time.Time)
+	if t.IsZero() {
+		return nil, nil
+	}
+
+	return t.Unix(), nil
+}
+
+func toString(v reflect.Value) (interface{}, error) {
+	return v.Interface().(fmt.Stringer).String(), nil
+}
+
+func TestValueConverter_Global(t *testing.T) {
+	stom.RegisterValueConverter(timeType, unixSeconds)
+	stom.RegisterValueConverter(stringerType, toString)
+	defer stom.UnregisterValueConverter(timeType)
+	defer stom.UnregisterValueConverter(stringerType)
+
+	updated := time.Unix(2000, 0)
+	converter := stom.MustNewStomWith(ConvertedItem{}, stom.NewConfig().WithDefault("DEFAULT"))
+
+	doTest(t, converter, ConvertedItem{
+		ID:      ItemID{1, 2, 3, 4},
+		Parent:  &ItemID{0, 0, 0, 1},
+		Created: time.Unix(1000, 0),
+		Updated: &updated,
+		Price:   Money{Cents: 150},
+		Label:   ItemID{0xff},
+		Count:   1,
+	}, map[string]interface{}{
+		"id":      "01020304",
+		"parent":  "00000001",
+		"created": int64(1000),
+		"updated": int64(2000),
+		"price":   map[string]interface{}{},
+		"label":   "ff000000",
+		"count":   "1",
+	})
+
+	// converter returning nil makes the value 'nil'
+	doTest(t, converter, ConvertedItem{}, map[string]interface{}{
+		"id":      "00000000",
+		"parent":  "DEFAULT",
+		"created": "DEFAULT",
+		"updated": "DEFAULT",
+		"price":   map[string]interface{}{},
+		"label":   "DEFAULT",
+		"count":   "0",
+	})
+}
+
+func TestValueConverter_PerConverter(t *testing.T) {
+	stom.RegisterValueConverter(stringerType, toString)
+	defer stom.UnregisterValueConverter(stringerType)
+
+	moneyType := reflect.TypeOf(Money{})
+	converter := stom.MustNewStomWith(ConvertedItem{}, stom.NewConfig().WithPolicy(stom.PolicyExclude)).
+		WithValueConverter(reflect.TypeOf(ItemID{}), func(v reflect.Value) (interface{}, error) {
+			return "id:" + v.Interface().(ItemID).String(), nil
+		}).
+		WithValueConverter(moneyType, func(v reflect.Value) (interface{}, error) {
+			return float64(v.Field(0).Int()) / 100, nil
+		})
+
+	// time.Time implements fmt.Stringer
+	expected := map[string]interface{}{
+		"id":      "id:01020304",
+		"created": time.Unix(1000, 0).String(),
+		"price":   1.5,
+		"count":   "0",
+	}
+	item := ConvertedItem{ID: ItemID{1, 2, 3, 4}, Created: time.Unix(1000, 0), Price: Money{Cents: 150}}
+
+	doTest(t, converter, item, expected)
+	doTest(t, stom.ToMapperFunc(func(s interface{}) (map[string]interface{}, error) {
+		return stom.ConvertToMapWith(s, converter.Config())
+	}), item, expected)
+
+	// nil removes converter
+	doTest(t, converter.WithValueConverter(moneyType, nil), item, map[string]interface{}{
+		"id":      "id:01020304",
+		"created": time.Unix(1000, 0).String(),
+		"price":   map[string]interface{}{},
+		"count":   "0",
+	})
+}
+
+func TestValueConverter_Error(t *testing.T) {
+	errConversion := errors.New("conversion failed")
+	converter := stom.MustNewStomWith(ConvertedItem{}, stom.NewConfig()).SetValueConverter(timeType, func(reflect.Value) (interface{}, error) {
+		return nil, errConversion
+	})
+
+	_, err := converter.ToMap(ConvertedItem{})
+	assert.True(t, errors.Is(err, errConversion))
+	assert.EqualError(t, err, "field Created (key created): conversion failed")
+}
+
+func TestValueConverter_StructFlatten(t *testing.T) {
+	type order struct {
+		ID    int    `db:"id"`
+		Price Money  `db:"price"`
+		Fee   *Money `db:"fee"`
+	}
+
+	moneyType := reflect.TypeOf(Money{})
+	cents := func(v reflect.Value) (interface{}, error) {
+		return v.Interface().(Money).Cents, nil
+	}
+	item := order{ID: 1, Price: Money{Cents: 150}, Fee: &Money{Cents: 5}}
+
+	// fields of Money are untagged, so they are named by Go names
+	config := stom.NewConfig().WithTags("db", stom.FieldName).WithStructMode(stom.StructFlatten)
+
+	m, err := stom.ConvertToMapWith(item, config)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": 1, "price.Cents": int64(150), "fee.Cents": int64(5)}, m)
+
+	// structures with converters are treated as values
+	expected := map[string]interface{}{"id": 1, "price": int64(150), "fee": int64(5)}
+	doTest(t, stom.MustNewStomWith(order{}, config.WithValueConverter(moneyType, cents)), item, expected)
+
+	converter := stom.MustNewStomWith(order{}, config)
+	assert.Equal(t, []string{"id", "price.Cents", "fee.Cents"}, converter.TagValues())
+	converter.SetValueConverter(moneyType, cents)
+	assert.Equal(t, []string{"id", "price", "fee"}, converter.TagValues())
+	doTest(t, converter, item, expected)
+
+	stom.RegisterValueConverter(moneyType, cents)
+	defer stom.UnregisterValueConverter(moneyType)
+
+	m, err = stom.ConvertToMapWith(item, config)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, m)
+}
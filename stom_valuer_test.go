@@ -0,0 +1,96 @@
+package stom_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elgris/stom"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnwrapValuer_ToMap(t *testing.T) {
+	defer restorePackageSettings(stom.PackageConfig())
+
+	stom.SetTag("db")
+	stom.SetPolicy(stom.PolicyExclude)
+	stom.SetUnwrapValuer(true)
+
+	expecteds := []map[string]interface{}{
+		map[string]interface{}{
+			"id":       1,
+			"name":     "item_1",
+			"number":   11,
+			"created":  time.Unix(10000, 0),
+			"updated":  time.Unix(11000, 0),
+			"discount": 111.0,
+			"price":    1111.0,
+			"reserved": true,
+			"points":   int64(11),
+			"rating":   1.0,
+			"visible":  true,
+		},
+		map[string]interface{}{
+			"id":      2,
+			"name":    "item_2",
+			"number":  22,
+			"created": time.Unix(20000, 0),
+			"price":   2222.0,
+			"visible": false,
+		},
+	}
+
+	doTestItems(t, stom.ToMapperFunc(stom.ConvertToMap), getTestItems()[:2], expecteds)
+}
+
+type ValuerItem struct {
+	Name   sql.NullString `db:"name"`
+	Points sql.NullInt64  `db:"points,default=10"`
+	Count  sql.NullInt32  `db:"count,string"`
+}
+
+func TestUnwrapValuer_RoundTrip(t *testing.T) {
+	converter := stom.MustNewStomWith(ValuerItem{}, stom.NewConfig()).WithUnwrapValuer(true)
+	assert.True(t, converter.Config().UnwrapValuer())
+
+	item := ValuerItem{
+		Name:  sql.NullString{String: "name", Valid: true},
+		Count: sql.NullInt32{Int32: 5, Valid: true},
+	}
+	expected := map[string]interface{}{"name": "name", "points": int64(10), "count": "5"}
+	doTest(t, converter, item, expected)
+
+	// default value stands for null
+	var actual ValuerItem
+	assert.NoError(t, converter.FromMap(expected, &actual))
+	assert.Equal(t, item, actual)
+}
+
+var errBrokenValuer = errors.New("broken valuer")
+
+type BrokenValuer struct{}
+
+// Value implements driver.Valuer
+func (BrokenValuer) Value() (driver.Value, error) {
+	return nil, errBrokenValuer
+}
+
+type BrokenValuerItem struct {
+	ID     int            `db:"id"`
+	Broken BrokenValuer   `db:"broken"`
+	Time   mysql.NullTime `db:"time"`
+}
+
+func TestUnwrapValuer_Error(t *testing.T) {
+	converter := stom.MustNewStomWith(BrokenValuerItem{}, stom.NewConfig().WithPolicy(stom.PolicyExclude))
+
+	// errors of Value() mean 'nil' unless values are unwrapped
+	doTest(t, converter, BrokenValuerItem{ID: 1}, map[string]interface{}{"id": 1})
+
+	_, err := converter.SetUnwrapValuer(true).ToMap(BrokenValuerItem{ID: 1})
+	assert.True(t, errors.Is(err, errBrokenValuer))
+	assert.EqualError(t, err, "field Broken (key broken): broken valuer")
+}
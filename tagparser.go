@@ -0,0 +1,173 @@
+package stom
+
+import (
+	"reflect"
+	"strings"
+)
+
+// TagParser extracts a key and options from a field of a structure.
+// It allows to use tags of third-party libraries, e.g. ORMs, instead of plain `db:"key"` tags.
+// ok is false if the field has no tag the parser understands
+type TagParser interface {
+	ParseTag(field reflect.StructField) (tag ParsedTag, ok bool)
+}
+
+// ParsedTag is a result of TagParser
+type ParsedTag struct {
+	// Name is a key of the field, empty name stands for the name of the field
+	Name string
+	// Options are tag options like "omitempty", "string" or "inline"
+	Options []string
+	// Skip excludes the field from conversions
+	Skip bool
+}
+
+// TagParserFunc defines a function that implements TagParser.
+// Functions cannot be compared, so analysis of types with it is not cached,
+// use NewTagParser for parsers used repeatedly
+type TagParserFunc func(field reflect.StructField) (ParsedTag, bool)
+
+// ParseTag implements TagParser
+func (f TagParserFunc) ParseTag(field reflect.StructField) (ParsedTag, bool) {
+	return f(field)
+}
+
+// NewTagParser makes TagParser from given function.
+// Analysis of types is cached per returned parser, so create it once
+// and reuse it for repeated conversions
+func NewTagParser(fn func(field reflect.StructField) (ParsedTag, bool)) TagParser {
+	return &funcParser{fn: fn}
+}
+
+// funcParser adapts a function to TagParser.
+// It's used by pointer, so analysis of types is cached per parser
+type funcParser struct {
+	fn func(field reflect.StructField) (ParsedTag, bool)
+}
+
+// ParseTag implements TagParser
+func (p *funcParser) ParseTag(field reflect.StructField) (ParsedTag, bool) {
+	return p.fn(field)
+}
+
+// Built-in tag parsers
+var (
+	// GormTag parses gorm tags like `gorm:"column:user_name;type:varchar(100)"`.
+	// Only fields with "column", "-" or "embedded" settings are recognized,
+	// "embedded" and "embeddedPrefix" are treated as "inline" and "prefix=" options
+	GormTag TagParser = gormTag{}
+
+	// BSONTag parses bson tags like `bson:"user_name,omitempty"`. As bson does,
+	// it names fields with empty key in tag by lowercased name of the field
+	BSONTag TagParser = bsonTag{}
+
+	// FieldNameTag gives every field its name, optionally changed by naming strategy.
+	// It's the same as FieldName pseudo tag and is meant to be the last one in a chain
+	FieldNameTag TagParser = fieldNameTag{}
+)
+
+// StructTag returns TagParser for tags of SToM format: `key:"name,option1,option2"`.
+// Tag "-" excludes the field, use "-," to get a literal dash key
+func StructTag(key string) TagParser {
+	return structTag(key)
+}
+
+type structTag string
+
+func (t structTag) ParseTag(field reflect.StructField) (ParsedTag, bool) {
+	tagValue := field.Tag.Get(string(t))
+	if tagValue == "" {
+		return ParsedTag{}, false
+	}
+
+	if tagValue == "-" {
+		return ParsedTag{Skip: true}, true
+	}
+
+	parts := strings.Split(tagValue, ",")
+
+	return ParsedTag{Name: parts[0], Options: parts[1:]}, true
+}
+
+type gormTag struct{}
+
+func (gormTag) ParseTag(field reflect.StructField) (ParsedTag, bool) {
+	tagValue := field.Tag.Get("gorm")
+	if tagValue == "" {
+		return ParsedTag{}, false
+	}
+
+	var parsed ParsedTag
+	var found bool
+	for _, setting := range strings.Split(tagValue, ";") {
+		key, value := setting, ""
+		if i := strings.Index(setting, ":"); i >= 0 {
+			key, value = setting[:i], setting[i+1:]
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "column":
+			parsed.Name = strings.TrimSpace(value)
+			found = true
+		case "-":
+			if value == "" || value == "all" {
+				return ParsedTag{Skip: true}, true
+			}
+		case "embedded":
+			parsed.Options = append(parsed.Options, "inline")
+			found = true
+		case "embeddedprefix":
+			parsed.Options = append(parsed.Options, "prefix="+value)
+		}
+	}
+
+	return parsed, found
+}
+
+type bsonTag struct{}
+
+func (bsonTag) ParseTag(field reflect.StructField) (ParsedTag, bool) {
+	parsed, ok := structTag("bson").ParseTag(field)
+	if ok && !parsed.Skip && parsed.Name == "" {
+		parsed.Name = strings.ToLower(field.Name)
+	}
+
+	return parsed, ok
+}
+
+type fieldNameTag struct{}
+
+func (fieldNameTag) ParseTag(field reflect.StructField) (ParsedTag, bool) {
+	return ParsedTag{Name: field.Name}, true
+}
+
+// tagParsers holds a chain of tag parsers set with Config.WithTagParsers
+type tagParsers []TagParser
+
+// key identifies the chain by values of parsers, so equal chains share analysis of types.
+// Returns true if some of parsers are not comparable, like TagParserFunc
+func (p tagParsers) key() (string, bool) {
+	keys := make([]string, len(p))
+	for i, parser := range p {
+		if parser == nil || !reflect.TypeOf(parser).Comparable() {
+			return "", true
+		}
+		keys[i] = internKey(parser)
+	}
+
+	return "parsers:" + strings.Join(keys, ","), false
+}
+
+// structTagParsers makes a chain of parsers for given chain of tags
+func structTagParsers(tags []string) []TagParser {
+	parsers := make([]TagParser, len(tags))
+	for i, tag := range tags {
+		if tag == FieldName {
+			parsers[i] = FieldNameTag
+		} else {
+			parsers[i] = StructTag(tag)
+		}
+	}
+
+	return parsers
+}
@@ -0,0 +1,468 @@
+package stom
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagOptions are options that can be listed in tag after the key,
+// e.g. `db:"name,omitempty,string"`
+type tagOptions struct {
+	// omitEmpty drops zero values of the field from resulting map
+	omitEmpty bool
+	// inline flattens a named struct field the same way as embedded one
+	inline bool
+	// asString puts string representation of the value into resulting map
+	asString bool
+	// structMode overrides StructMode setting for the field
+	structMode *StructMode
+	// prefix is prepended to keys of flattened embedded or struct field
+	prefix string
+	// policy overrides Policy setting for the field
+	policy *Policy
+	// defaultLiteral is a default value of the field as written in tag
+	defaultLiteral *string
+	// defaultValue is defaultLiteral parsed against type of the field,
+	// it overrides default value setting for the field
+	defaultValue interface{}
+	// err is an error of parsing of the options
+	err error
+}
+
+// field describes a single field found in structure by tag
+type field struct {
+	name string
+	opts tagOptions
+	// index is a sequence of indices to reach the field from the root structure,
+	// fields of embedded structures have more than one index
+	index []int
+	// path is a Go path to the field, e.g. "BasicItem.ParentItem.Base"
+	path string
+	// tagged tells whether the key comes from a tag, not from naming strategy
+	tagged bool
+	// nested holds tags of a struct field that is converted to nested map
+	// or of elements of a slice field that are converted to nested maps
+	nested *tags
+	// indexed tells that elements of a slice field are flattened with indexed keys
+	indexed bool
+}
+
+// policy returns policy for 'nil' values of the field
+func (f field) policy(config Config) Policy {
+	if f.opts.policy != nil {
+		return *f.opts.policy
+	}
+
+	return config.policy
+}
+
+// defaultValue returns value that is put instead of 'nil' value of the field
+func (f field) defaultValue(config Config) interface{} {
+	if f.opts.defaultLiteral == nil {
+		return config.defaultValue
+	}
+
+	if valuer, ok := f.opts.defaultValue.(driver.Valuer); ok && config.unwrapValuer {
+		if value, err := valuer.Value(); err == nil {
+			return value
+		}
+	}
+
+	return f.opts.defaultValue
+}
+
+// tags is a list of fields of a structure resolved by tag.
+// Every key appears in the list only once
+type tags struct {
+	Fields []field
+	// Conflicts lists keys produced by several fields, including nested maps
+	Conflicts []KeyConflict
+	// Errors lists invalid tag options of fields as *FieldError, including nested maps
+	Errors []error
+}
+
+func (t tags) TagsList() []string {
+	tagsList := make([]string, len(t.Fields))
+	for i, f := range t.Fields {
+		tagsList[i] = f.name
+	}
+
+	return tagsList
+}
+
+// parseOptions parses options listed in tag after the key.
+// Unknown options are ignored
+func parseOptions(options []string) (opts tagOptions) {
+	for _, option := range options {
+		switch {
+		case strings.HasPrefix(option, "prefix="):
+			opts.prefix = strings.TrimPrefix(option, "prefix=")
+		case strings.HasPrefix(option, "default="):
+			literal := strings.TrimPrefix(option, "default=")
+			opts.defaultLiteral = &literal
+		case strings.HasPrefix(option, "policy="):
+			name := strings.TrimPrefix(option, "policy=")
+			if policy, ok := policyNames[name]; ok {
+				opts.policy = &policy
+			} else {
+				opts.err = fmt.Errorf("unknown policy %s", name)
+			}
+		case option == "omitempty":
+			opts.omitEmpty = true
+		case option == "inline":
+			opts.inline = true
+		case option == "string":
+			opts.asString = true
+		case option == "value":
+			opts.structMode = structModePtr(StructAsValue)
+		case option == "nest":
+			opts.structMode = structModePtr(StructNest)
+		case option == "flatten":
+			opts.structMode = structModePtr(StructFlatten)
+		}
+	}
+
+	return opts
+}
+
+// sliceMode returns mode for the field of slice type, tag options of struct mode override the setting
+func (opts tagOptions) sliceMode(config Config) SliceMode {
+	if opts.structMode == nil {
+		return config.sliceMode
+	}
+
+	switch *opts.structMode {
+	case StructNest:
+		return SliceNest
+	case StructFlatten:
+		return SliceFlatten
+	}
+
+	return SliceAsValue
+}
+
+// resolveDefault parses default value of a field of given type.
+// Field with default value and without policy gets PolicyUseDefault
+func (opts *tagOptions) resolveDefault(typ reflect.Type) {
+	if opts.defaultLiteral == nil || opts.err != nil {
+		return
+	}
+
+	opts.defaultValue, opts.err = parseDefault(*opts.defaultLiteral, typ)
+	if opts.policy == nil {
+		opts.policy = policyPtr(PolicyUseDefault)
+	}
+}
+
+// parseDefault parses default value literal against given type. Basic types,
+// types implementing sql.Scanner or encoding.TextUnmarshaler and pointers to them are supported
+func parseDefault(literal string, typ reflect.Type) (interface{}, error) {
+	typ = derefType(typ)
+
+	var parsed interface{}
+	var err error
+	switch typ.Kind() {
+	case reflect.Bool:
+		parsed, err = strconv.ParseBool(literal)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err = strconv.ParseInt(literal, 10, typ.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err = strconv.ParseUint(literal, 10, typ.Bits())
+	case reflect.Float32, reflect.Float64:
+		parsed, err = strconv.ParseFloat(literal, typ.Bits())
+	case reflect.String:
+		parsed = literal
+	}
+
+	if parsed != nil || err != nil {
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value %q for type %s", literal, typ)
+		}
+		return reflect.ValueOf(parsed).Convert(typ).Interface(), nil
+	}
+
+	value := reflect.New(typ)
+	switch target := value.Interface().(type) {
+	case sql.Scanner:
+		err = target.Scan(literal)
+	case encoding.TextUnmarshaler:
+		err = target.UnmarshalText([]byte(literal))
+	default:
+		return nil, fmt.Errorf("default value is not supported for type %s", typ)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid default value %q for type %s: %s", literal, typ, err.Error())
+	}
+
+	return value.Elem().Interface(), nil
+}
+
+// extractTagValues scans given type and tries to find all fields with given tag.
+// If several fields produce the same key, the shallowest one wins, fields on the
+// same depth cancel each other out. All such conflicts are recorded
+func extractTagValues(typ reflect.Type, config Config) tags {
+	config.scanConfig = config.scanConfig.effective()
+	return resolveFields(extractFields(typ, config, config.TagParsers(), nil, map[reflect.Type]bool{}))
+}
+
+// lookupTag finds the first parser of the chain that recognizes given field.
+// If there is no such parser, the field is named with naming strategy, if any
+func lookupTag(structField reflect.StructField, chain []TagParser, naming NamingStrategy) (name string, opts tagOptions, found, tagged, skip bool) {
+	for _, parser := range chain {
+		if _, isFieldName := parser.(fieldNameTag); isFieldName {
+			return nameField(structField, naming), opts, true, false, false
+		}
+
+		if parsed, ok := parser.ParseTag(structField); ok {
+			return parsed.Name, parseOptions(parsed.Options), true, true, parsed.Skip
+		}
+	}
+
+	if naming != nil {
+		return nameField(structField, naming), opts, true, false, false
+	}
+
+	return "", opts, false, false, false
+}
+
+// nameField makes a key for untagged field
+func nameField(structField reflect.StructField, naming NamingStrategy) string {
+	if naming == nil {
+		return structField.Name
+	}
+
+	return naming.Name(structField.Name)
+}
+
+// extractFields does the job of extractTagValues without resolving duplicates.
+// Registered mappings of the type are merged with given mapping of outer type,
+// which is relative to the type. It keeps track of struct types being scanned
+// to not fall into infinite recursion on self-referencing types
+func extractFields(typ reflect.Type, config Config, chain []TagParser, mapping fieldMappings, visiting map[reflect.Type]bool) []field {
+	typ = derefType(typ)
+	fields := []field{}
+
+	if !config.jsonCompat {
+		mapping = registeredMappings(typ).merge(mapping)
+	}
+
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		name, opts, found, tagged, skip := lookupTag(structField, chain, config.naming)
+		if mapped, ok := mapping[structField.Name]; ok {
+			name, opts, found, tagged, skip = mapped.apply(name, opts, found, tagged, skip)
+		}
+
+		if skip {
+			continue
+		}
+
+		if config.jsonCompat {
+			name, opts, tagged = jsonTagRules(structField, name, opts, tagged)
+		}
+
+		// encoding/json treats embedded structures with keys in tags as named fields
+		embedded := structField.Anonymous && !(config.jsonCompat && tagged)
+
+		if (embedded || opts.inline) && isStruct(structField.Type) {
+			if visiting[derefType(structField.Type)] {
+				continue
+			}
+			nested := extractFields(structField.Type, config, chain, mapping.sub(structField.Name), visiting)
+			fields = append(fields, embedFields(i, structField.Name, opts.prefix, nested)...)
+			continue
+		}
+
+		if !found || structField.PkgPath != "" { // not tagged or not exported
+			continue
+		}
+
+		if name == "" {
+			name = structField.Name
+		}
+		opts.resolveDefault(structField.Type)
+		f := field{name: name, opts: opts, index: []int{i}, path: structField.Name, tagged: tagged}
+
+		mode := config.structMode
+		if opts.structMode != nil {
+			mode = *opts.structMode
+		}
+
+		if mode != StructAsValue && isPlainStruct(structField.Type, config) && !visiting[derefType(structField.Type)] {
+			nested := extractFields(structField.Type, config, chain, mapping.sub(structField.Name), visiting)
+			if mode == StructFlatten {
+				prefix := opts.prefix
+				if prefix == "" {
+					prefix = name + config.separator
+				}
+				fields = append(fields, embedFields(i, structField.Name, prefix, nested)...)
+				continue
+			}
+			nestedTags := resolveFields(nested)
+			f.nested = &nestedTags
+		}
+
+		sliceMode := opts.sliceMode(config)
+		if sliceMode != SliceAsValue && isStructSlice(structField.Type, config) && !visiting[derefType(structField.Type.Elem())] {
+			nested := extractFields(structField.Type.Elem(), config, chain, nil, visiting)
+			nestedTags := resolveFields(nested)
+			f.nested = &nestedTags
+			f.indexed = sliceMode == SliceFlatten
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// embedFields makes fields of a flattened structure reachable from its parent
+func embedFields(index int, name, prefix string, nested []field) []field {
+	for i := range nested {
+		nested[i].name = prefix + nested[i].name
+		nested[i].index = append([]int{index}, nested[i].index...)
+		nested[i].path = name + "." + nested[i].path
+	}
+
+	return nested
+}
+
+// resolveFields applies dominance rules to fields with duplicate keys,
+// like encoding/json does: the field with the shortest index sequence wins,
+// tagged field wins over the named one on the same depth,
+// if there are still several of them, the key is dropped
+func resolveFields(fields []field) tags {
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+
+		return fields[i].tagged && !fields[j].tagged
+	})
+
+	resolved := tags{Fields: []field{}}
+	for start := 0; start < len(fields); {
+		end := start + 1
+		for end < len(fields) && fields[end].name == fields[start].name {
+			end++
+		}
+
+		group := fields[start:end]
+		if len(group) == 1 || dominates(group[0], group[1]) {
+			resolved.Fields = append(resolved.Fields, group[0])
+		}
+
+		if len(group) > 1 {
+			conflict := KeyConflict{Key: group[0].name}
+			for _, f := range group {
+				conflict.Paths = append(conflict.Paths, f.path)
+			}
+			resolved.Conflicts = append(resolved.Conflicts, conflict)
+		}
+
+		start = end
+	}
+
+	for _, f := range resolved.Fields {
+		if f.nested != nil {
+			for _, c := range f.nested.Conflicts {
+				paths := make([]string, len(c.Paths))
+				for i, path := range c.Paths {
+					paths[i] = f.path + "." + path
+				}
+				resolved.Conflicts = append(resolved.Conflicts, KeyConflict{Key: f.name + "." + c.Key, Paths: paths})
+			}
+		}
+	}
+
+	sort.SliceStable(resolved.Fields, func(i, j int) bool {
+		return lessIndex(resolved.Fields[i].index, resolved.Fields[j].index)
+	})
+
+	for _, f := range resolved.Fields {
+		if f.opts.err != nil {
+			resolved.Errors = append(resolved.Errors, newFieldError(f, f.opts.err))
+		}
+		if f.nested != nil {
+			for _, err := range f.nested.Errors {
+				resolved.Errors = append(resolved.Errors, newFieldError(f, err))
+			}
+		}
+	}
+
+	return resolved
+}
+
+// dominates checks whether the first field of sorted group wins over the second one
+func dominates(first, second field) bool {
+	if len(first.index) != len(second.index) {
+		return true
+	}
+
+	return first.tagged && !second.tagged
+}
+
+// lessIndex orders index sequences in order of declaration of fields
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+func structModePtr(mode StructMode) *StructMode {
+	return &mode
+}
+
+func policyPtr(policy Policy) *Policy {
+	return &policy
+}
+
+func derefType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+
+	return typ
+}
+
+// isPlainStruct checks whether given type is a struct that SToM may look into.
+// Structs that are recognized by hooks, like time.Time or sql.NullString,
+// or converted by value converters are treated as values
+func isPlainStruct(typ reflect.Type, config Config) bool {
+	if derefType(typ).Kind() != reflect.Struct {
+		return false
+	}
+
+	return !implementsHooks(derefType(typ), config.hooks) && !hasConverter(typ, config)
+}
+
+// isStructSlice checks whether given type is a slice or an array of structs that SToM may look into
+func isStructSlice(typ reflect.Type, config Config) bool {
+	if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+		return false
+	}
+
+	return !implementsHooks(typ, config.hooks) && !hasConverter(typ, config) && isPlainStruct(typ.Elem(), config)
+}
+
+func isStruct(typ reflect.Type) bool {
+	return derefType(typ).Kind() == reflect.Struct
+}
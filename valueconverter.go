@@ -0,0 +1,191 @@
+package stom
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ValueConverter converts value of a field before it's put into resulting map,
+// e.g. uuid.UUID to string or time.Time to Unix seconds.
+// Returned 'nil' is treated as 'nil' value of the field according to policy.
+// Converters don't get nil pointers and interfaces, such values stay 'nil'
+type ValueConverter func(v reflect.Value) (interface{}, error)
+
+// valueConverters is an immutable set of value converters
+type valueConverters struct {
+	// types holds converters of concrete types
+	types map[reflect.Type]ValueConverter
+	// interfaces holds converters of interfaces in order of registration
+	interfaces []interfaceConverter
+}
+
+type interfaceConverter struct {
+	typ     reflect.Type
+	convert ValueConverter
+}
+
+// with returns a copy of the set with given converter added or replaced.
+// nil converter removes converter of given type
+func (c *valueConverters) with(typ reflect.Type, convert ValueConverter) *valueConverters {
+	updated := &valueConverters{types: map[reflect.Type]ValueConverter{}}
+
+	if c != nil {
+		for t, converter := range c.types {
+			updated.types[t] = converter
+		}
+		for _, converter := range c.interfaces {
+			if converter.typ != typ {
+				updated.interfaces = append(updated.interfaces, converter)
+			}
+		}
+	}
+
+	switch {
+	case convert == nil:
+		delete(updated.types, typ)
+	case typ.Kind() == reflect.Interface:
+		updated.interfaces = append(updated.interfaces, interfaceConverter{typ: typ, convert: convert})
+	default:
+		updated.types[typ] = convert
+	}
+
+	return updated
+}
+
+// lookupValue finds converter of a value or of values it references through
+// pointers and interfaces. Converters of concrete types go first
+func (c *valueConverters) lookupValue(value reflect.Value) (ValueConverter, reflect.Value, bool) {
+	if c.empty() {
+		return nil, value, false
+	}
+
+	for _, lookup := range []func(reflect.Type) (ValueConverter, bool){c.lookupType, c.lookupInterface} {
+		for v := value; ; v = v.Elem() {
+			isReference := v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface
+			if isReference && v.IsNil() {
+				break
+			}
+
+			if converter, ok := lookup(v.Type()); ok {
+				return converter, v, true
+			}
+
+			if !isReference {
+				break
+			}
+		}
+	}
+
+	return nil, value, false
+}
+
+func (c *valueConverters) lookupType(typ reflect.Type) (ValueConverter, bool) {
+	converter, ok := c.types[typ]
+	return converter, ok
+}
+
+func (c *valueConverters) lookupInterface(typ reflect.Type) (ValueConverter, bool) {
+	for _, converter := range c.interfaces {
+		if typ.Implements(converter.typ) {
+			return converter.convert, true
+		}
+	}
+
+	return nil, false
+}
+
+// converts checks whether there is a converter of given type or of types it references through pointers
+func (c *valueConverters) converts(typ reflect.Type) bool {
+	if c.empty() {
+		return false
+	}
+
+	for t := typ; ; t = t.Elem() {
+		if _, ok := c.lookupType(t); ok {
+			return true
+		}
+		if _, ok := c.lookupInterface(t); ok {
+			return true
+		}
+
+		if t.Kind() != reflect.Ptr {
+			return false
+		}
+	}
+}
+
+// key encodes types of the converters into comparable string
+func (c *valueConverters) key() string {
+	if c.empty() {
+		return ""
+	}
+
+	var keys []string
+	for typ := range c.types {
+		keys = append(keys, internKey(typ))
+	}
+	for _, converter := range c.interfaces {
+		keys = append(keys, internKey(converter.typ))
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
+
+func (c *valueConverters) empty() bool {
+	return c == nil || (len(c.types) == 0 && len(c.interfaces) == 0)
+}
+
+// Global value converters
+var (
+	convertersMutex  sync.RWMutex
+	globalConverters *valueConverters
+)
+
+// RegisterValueConverter attaches given converter to a concrete type or to an interface
+// for all conversions to maps. Converters are consulted before hooks, converters set with
+// Config.WithValueConverter go first. Converter of a type is used for pointers to the type too.
+// Structures converted by converters are never nested or flattened, SToMs created before
+// the registration are not affected by this.
+// Use reflect.TypeOf((*SomeInterface)(nil)).Elem() to get type of an interface
+func RegisterValueConverter(typ reflect.Type, converter ValueConverter) {
+	convertersMutex.Lock()
+	globalConverters = globalConverters.with(typ, converter)
+	convertersMutex.Unlock()
+
+	ResetCache()
+}
+
+// UnregisterValueConverter drops global converter of given type
+func UnregisterValueConverter(typ reflect.Type) {
+	RegisterValueConverter(typ, nil)
+}
+
+func registeredConverters() *valueConverters {
+	convertersMutex.RLock()
+	defer convertersMutex.RUnlock()
+
+	return globalConverters
+}
+
+// hasConverter checks whether values of given type are converted by converters
+// from settings or by global ones
+func hasConverter(typ reflect.Type, config Config) bool {
+	return config.converters.converts(typ) || registeredConverters().converts(typ)
+}
+
+// convertValue converts value of a field with converters from settings,
+// then with global ones. Nil pointers and interfaces are never converted.
+// Returns false if there is no converter for the value
+func convertValue(vField reflect.Value, config Config) (interface{}, bool, error) {
+	for _, converters := range []*valueConverters{config.converters, registeredConverters()} {
+		if converter, v, ok := converters.lookupValue(vField); ok {
+			converted, err := converter(v)
+			return converted, true, err
+		}
+	}
+
+	return nil, false, nil
+}